@@ -0,0 +1,163 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+var adhocFiltersRegex = regexp.MustCompile(`\$__adhocFilters\b`)
+
+// ApplyAdhocFilters applies Grafana ad hoc filters to a macro-expanded SQL
+// string. Occurrences of $__adhocFilters are replaced with the filter
+// condition. Queries without the macro are wrapped as a subselect and
+// filtered on their result columns, unless they are not queries, such as
+// PRAGMA statements. Filter values are returned as positional arguments for
+// the generated ? placeholders.
+func ApplyAdhocFilters(sql string, filters []AdhocFilter) (string, []interface{}, error) {
+	condition, args, err := buildAdhocCondition(filters)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if adhocFiltersRegex.MatchString(sql) {
+		var allArgs []interface{}
+		sql = adhocFiltersRegex.ReplaceAllStringFunc(sql, func(string) string {
+			allArgs = append(allArgs, args...)
+			return condition
+		})
+		return sql, allArgs, nil
+	}
+
+	if len(filters) == 0 || !isSelect(sql) {
+		return sql, nil, nil
+	}
+	return subselect(sql) + " WHERE " + condition, args, nil
+}
+
+// isSelect reports whether a statement is a query that can be wrapped as a
+// subselect.
+func isSelect(sql string) bool {
+	tokens := tokenizeSQL(sql)
+	return len(tokens) > 0 && (tokens[0].is("SELECT") || tokens[0].is("VALUES") || tokens[0].is("WITH"))
+}
+
+// subselect wraps a query as a subselect, dropping trailing semicolons and
+// comments, which would otherwise swallow the closing parenthesis.
+func subselect(sql string) string {
+	tokens := tokenizeSQL(sql)
+	end := len(tokens)
+	for end > 0 && tokens[end-1].is(";") {
+		end--
+	}
+	inner := ""
+	if end > 0 {
+		inner = sql[tokens[0].start:tokens[end-1].end]
+	}
+	return "SELECT * FROM (" + inner + ")"
+}
+
+// applicableAdhocFilters returns the ad hoc filters on columns a query can be
+// filtered on, so that a dashboard's filters leave panels without the column
+// alone: the columns of the tables it reads if it uses $__adhocFilters, and
+// its result columns otherwise. Queries that cannot be wrapped as a
+// subselect get no filters.
+func (d *Datasource) applicableAdhocFilters(ctx context.Context, sql string, filters []AdhocFilter) ([]AdhocFilter, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	var columns []string
+	switch {
+	case adhocFiltersRegex.MatchString(sql):
+		schema, err := d.schemaColumns(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range referencedTables(sql) {
+			for name, cols := range schema {
+				if !strings.EqualFold(name, table) {
+					continue
+				}
+				for _, col := range cols {
+					columns = append(columns, col.Name)
+				}
+			}
+		}
+	case isSelect(sql):
+		// The query's own errors are reported when it runs.
+		result, err := d.queryResult(ctx, subselect(sql)+" LIMIT 0")
+		if err != nil {
+			log.DefaultLogger.Debug("Not applying ad hoc filters to a query that cannot be wrapped", "error", err)
+			return nil, nil
+		}
+		columns = result.Columns
+	}
+
+	var applicable []AdhocFilter
+	for _, f := range filters {
+		if slices.ContainsFunc(columns, func(col string) bool { return strings.EqualFold(col, f.Key) }) {
+			applicable = append(applicable, f)
+		}
+	}
+	return applicable, nil
+}
+
+// buildAdhocCondition combines filters into a single parenthesized condition.
+// An empty filter list yields the always-true condition "1=1".
+func buildAdhocCondition(filters []AdhocFilter) (string, []interface{}, error) {
+	if len(filters) == 0 {
+		return "1=1", nil, nil
+	}
+
+	parts := make([]string, 0, len(filters))
+	var args []interface{}
+
+	for _, f := range filters {
+		if f.Key == "" {
+			return "", nil, fmt.Errorf("ad hoc filter is missing a key")
+		}
+		column := quoteIdentifier(f.Key)
+
+		switch f.Operator {
+		case "=", "!=", "<", ">", "<=", ">=":
+			parts = append(parts, fmt.Sprintf("%s %s ?", column, f.Operator))
+			args = append(args, adhocValue(f.Value))
+		case "=|", "!=|":
+			values := f.Values
+			if len(values) == 0 {
+				values = []string{f.Value}
+			}
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+			op := "IN"
+			if f.Operator == "!=|" {
+				op = "NOT IN"
+			}
+			parts = append(parts, fmt.Sprintf("%s %s (%s)", column, op, placeholders))
+			for _, v := range values {
+				args = append(args, adhocValue(v))
+			}
+		default:
+			return "", nil, fmt.Errorf("unsupported ad hoc filter operator %q", f.Operator)
+		}
+	}
+
+	return "(" + strings.Join(parts, " AND ") + ")", args, nil
+}
+
+// adhocValue converts a filter value to a number if it is one in canonical
+// form, so that it compares correctly against both numeric and text columns.
+func adhocValue(s string) interface{} {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(n, 10) == s {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == s {
+		return f
+	}
+	return s
+}
@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestApplyAdhocFilters(t *testing.T) {
+	tests := []struct {
+		name         string
+		sql          string
+		filters      []AdhocFilter
+		expectedSQL  string
+		expectedArgs []interface{}
+	}{
+		{
+			name:        "no filters without macro",
+			sql:         "SELECT * FROM hosts",
+			expectedSQL: "SELECT * FROM hosts",
+		},
+		{
+			name:        "no filters with macro",
+			sql:         "SELECT * FROM hosts WHERE $__adhocFilters",
+			expectedSQL: "SELECT * FROM hosts WHERE 1=1",
+		},
+		{
+			name: "macro",
+			sql:  "SELECT * FROM hosts WHERE ts > 0 AND $__adhocFilters ORDER BY ts",
+			filters: []AdhocFilter{
+				{Key: "host", Operator: "=", Value: "web-1"},
+				{Key: "cpu", Operator: ">=", Value: "80"},
+			},
+			expectedSQL:  `SELECT * FROM hosts WHERE ts > 0 AND ("host" = ? AND "cpu" >= ?) ORDER BY ts`,
+			expectedArgs: []interface{}{"web-1", int64(80)},
+		},
+		{
+			name:         "wrapped as subselect",
+			sql:          "SELECT host, cpu FROM hosts;\n",
+			filters:      []AdhocFilter{{Key: `odd"key`, Operator: "!=", Value: "1.5"}},
+			expectedSQL:  `SELECT * FROM (SELECT host, cpu FROM hosts) WHERE ("odd""key" != ?)`,
+			expectedArgs: []interface{}{1.5},
+		},
+		{
+			name:         "trailing comment",
+			sql:          "SELECT host FROM hosts; -- all hosts",
+			filters:      []AdhocFilter{{Key: "host", Operator: "=", Value: "web-1"}},
+			expectedSQL:  `SELECT * FROM (SELECT host FROM hosts) WHERE ("host" = ?)`,
+			expectedArgs: []interface{}{"web-1"},
+		},
+		{
+			name:        "not a query",
+			sql:         "PRAGMA table_info(hosts)",
+			filters:     []AdhocFilter{{Key: "name", Operator: "=", Value: "host"}},
+			expectedSQL: "PRAGMA table_info(hosts)",
+		},
+		{
+			name: "multi-value operators",
+			sql:  "SELECT * FROM hosts WHERE $__adhocFilters",
+			filters: []AdhocFilter{
+				{Key: "host", Operator: "=|", Values: []string{"web-1", "web-2"}},
+				{Key: "dc", Operator: "!=|", Values: []string{"007"}},
+			},
+			expectedSQL:  `SELECT * FROM hosts WHERE ("host" IN (?, ?) AND "dc" NOT IN (?))`,
+			expectedArgs: []interface{}{"web-1", "web-2", "007"},
+		},
+		{
+			name:         "macro used twice",
+			sql:          "SELECT * FROM a WHERE $__adhocFilters UNION SELECT * FROM b WHERE $__adhocFilters",
+			filters:      []AdhocFilter{{Key: "host", Operator: "=", Value: "web-1"}},
+			expectedSQL:  `SELECT * FROM a WHERE ("host" = ?) UNION SELECT * FROM b WHERE ("host" = ?)`,
+			expectedArgs: []interface{}{"web-1", "web-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := ApplyAdhocFilters(tt.sql, tt.filters)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sql != tt.expectedSQL {
+				t.Errorf("expected %q, got %q", tt.expectedSQL, sql)
+			}
+			if !reflect.DeepEqual(args, tt.expectedArgs) {
+				t.Errorf("expected args %#v, got %#v", tt.expectedArgs, args)
+			}
+		})
+	}
+}
+
+func TestApplyAdhocFilters_UnsupportedOperator(t *testing.T) {
+	_, _, err := ApplyAdhocFilters("SELECT * FROM hosts", []AdhocFilter{{Key: "host", Operator: "=~", Value: "web.*"}})
+	if err == nil {
+		t.Fatal("expected error for regex operator")
+	}
+}
+
+func TestApplicableAdhocFilters_Macro(t *testing.T) {
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		result := RqliteResult{
+			Columns: []string{"table", "name", "type"},
+			Values:  [][]interface{}{{"hosts", "host", "text"}, {"hosts", "cpu", "real"}, {"other", "dc", "text"}},
+		}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+
+	filters, err := ds.applicableAdhocFilters(context.Background(), "SELECT * FROM Hosts WHERE $__adhocFilters", []AdhocFilter{
+		{Key: "HOST", Operator: "=", Value: "web-1"},
+		{Key: "dc", Operator: "=", Value: "eu"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(filters) != 1 || filters[0].Key != "HOST" {
+		t.Errorf("expected only the filter on a column of the queried table, got %v", filters)
+	}
+}
@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// Audit SQL modes, controlling how much of a query's SQL the audit log
+// records besides its fingerprint.
+const (
+	auditSQLNormalized = "normalized" // SQL with literals replaced by ?, the default
+	auditSQLRaw        = "raw"        // SQL as executed, including literals
+	auditSQLNone       = "none"       // fingerprint only
+)
+
+// auditLog writes an audit record per data query through a plugin logger.
+type auditLog struct {
+	logger  log.Logger
+	sqlMode string
+}
+
+// newAuditLog returns the audit log for the settings, or nil if auditing is
+// disabled.
+func newAuditLog(settings PluginSettings, logger log.Logger) *auditLog {
+	if !settings.AuditLog {
+		return nil
+	}
+	mode := settings.AuditSQL
+	if mode == "" {
+		mode = auditSQLNormalized
+	}
+	return &auditLog{logger: logger.With("logger", "audit"), sqlMode: mode}
+}
+
+// record logs who ran a query, what it ran and how it went. A nil *auditLog
+// records nothing.
+func (a *auditLog) record(req *backend.QueryDataRequest, query backend.DataQuery, sql string, rows int, start time.Time, res backend.DataResponse) {
+	if a == nil {
+		return
+	}
+
+	pCtx := req.PluginContext
+	args := []interface{}{
+		"orgID", pCtx.OrgID,
+		"refID", query.RefID,
+		"dashboardUID", req.GetHTTPHeader("X-Dashboard-Uid"),
+		"panelID", req.GetHTTPHeader("X-Panel-Id"),
+		"duration", time.Since(start).String(),
+		"rows", rows,
+	}
+	if pCtx.User != nil {
+		args = append(args, "user", pCtx.User.Login)
+	}
+	if pCtx.DataSourceInstanceSettings != nil {
+		args = append(args, "datasourceUID", pCtx.DataSourceInstanceSettings.UID)
+	}
+	if sql != "" {
+		args = append(args, "fingerprint", sqlFingerprint(sql))
+		switch a.sqlMode {
+		case auditSQLRaw:
+			args = append(args, "sql", sql)
+		case auditSQLNone:
+		default:
+			args = append(args, "sql", normalizeSQL(sql))
+		}
+	}
+	if res.Error != nil {
+		args = append(args, "outcome", "error", "status", strconv.Itoa(int(res.Status)), "error", res.Error.Error())
+	} else {
+		args = append(args, "outcome", "success")
+	}
+
+	a.logger.Info("Query audit", args...)
+}
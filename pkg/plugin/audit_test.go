@@ -0,0 +1,99 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// recordLogger records the key-value pairs of Info calls.
+type recordLogger struct {
+	log.Logger
+	records []map[string]interface{}
+}
+
+func (l *recordLogger) With(...interface{}) log.Logger {
+	return l
+}
+
+func (l *recordLogger) Info(_ string, args ...interface{}) {
+	record := map[string]interface{}{}
+	for i := 0; i+1 < len(args); i += 2 {
+		record[args[i].(string)] = args[i+1]
+	}
+	l.records = append(l.records, record)
+}
+
+func TestAuditLog(t *testing.T) {
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		result := RqliteResult{Columns: []string{"v"}, Types: []string{"integer"}, Values: [][]interface{}{{1.0}, {2.0}}}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+
+	const sql = "SELECT v FROM t WHERE host = 'secret'"
+	qmJSON, _ := json.Marshal(QueryModel{RawSQL: sql, Format: "table"})
+	req := &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{
+			OrgID:                      3,
+			User:                       &backend.User{Login: "alice"},
+			DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{UID: "ds-1"},
+		},
+		Headers: map[string]string{"http_X-Dashboard-Uid": "dash-1", "http_X-Panel-Id": "7"},
+		Queries: []backend.DataQuery{
+			{RefID: "A", JSON: qmJSON},
+			{RefID: "B", JSON: []byte(`{"rawSql":""}`)},
+		},
+	}
+
+	tests := []struct {
+		mode    string
+		wantSQL interface{}
+	}{
+		{"", "SELECT v FROM t WHERE host = ?"},
+		{auditSQLRaw, sql},
+		{auditSQLNone, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			logger := &recordLogger{}
+			ds.audit = newAuditLog(PluginSettings{AuditLog: true, AuditSQL: tt.mode}, logger)
+			if _, err := ds.QueryData(context.Background(), req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(logger.records) != 2 {
+				t.Fatalf("expected 2 audit records, got %d", len(logger.records))
+			}
+
+			ok := logger.records[0]
+			if ok["refID"] == "B" {
+				ok = logger.records[1]
+			}
+			want := map[string]interface{}{
+				"user":          "alice",
+				"orgID":         int64(3),
+				"datasourceUID": "ds-1",
+				"dashboardUID":  "dash-1",
+				"panelID":       "7",
+				"rows":          2,
+				"outcome":       "success",
+				"fingerprint":   sqlFingerprint(sql),
+				"sql":           tt.wantSQL,
+			}
+			for key, value := range want {
+				if ok[key] != value {
+					t.Errorf("%s: got %v, want %v", key, ok[key], value)
+				}
+			}
+		})
+	}
+
+	ds.audit = newAuditLog(PluginSettings{}, &recordLogger{})
+	if ds.audit != nil {
+		t.Error("expected auditing to be disabled by default")
+	}
+}
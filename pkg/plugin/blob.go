@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// BLOB modes, controlling how BLOB columns are rendered.
+const (
+	blobModeBase64  = "base64" // base64 text, the default
+	blobModeHex     = "hex"    // lowercase hex text
+	blobModeUTF8    = "utf8"   // decoded as UTF-8, invalid bytes replaced by U+FFFD
+	blobModeLength  = "length" // number of bytes
+	blobModeDataURI = "datauri"
+)
+
+func validBlobMode(mode string) bool {
+	switch mode {
+	case blobModeBase64, blobModeHex, blobModeUTF8, blobModeLength, blobModeDataURI:
+		return true
+	default:
+		return false
+	}
+}
+
+func newBlobField(name, mode string, capacity int) *data.Field {
+	if mode == blobModeLength {
+		return data.NewField(name, nil, make([]*int64, 0, capacity))
+	}
+	return data.NewField(name, nil, make([]*string, 0, capacity))
+}
+
+func appendBlobValue(field *data.Field, val interface{}, mode string) {
+	b, ok := blobBytes(val)
+	if !ok {
+		appendNilValue(field)
+		return
+	}
+
+	if mode == blobModeLength {
+		n := int64(len(b))
+		field.Append(&n)
+		return
+	}
+
+	var s string
+	switch mode {
+	case blobModeHex:
+		s = hex.EncodeToString(b)
+	case blobModeUTF8:
+		s = strings.ToValidUTF8(string(b), string(utf8.RuneError))
+	case blobModeDataURI:
+		s = "data:" + http.DetectContentType(b) + ";base64," + base64.StdEncoding.EncodeToString(b)
+	default:
+		s = base64.StdEncoding.EncodeToString(b)
+	}
+	field.Append(&s)
+}
+
+// blobBytes returns the bytes of a value of a BLOB column. Queries request
+// rqlite's blob_array option, so BLOB values are arrays of byte values, while
+// strings are text stored in the column and taken as is.
+func blobBytes(val interface{}) ([]byte, bool) {
+	switch v := val.(type) {
+	case string:
+		return []byte(v), true
+	case []interface{}:
+		return byteArray(v)
+	case nil:
+		return nil, false
+	default:
+		return []byte(fmt.Sprintf("%v", v)), true
+	}
+}
+
+// byteArray converts a blob_array value to bytes. It reports false if any
+// element is not a byte value.
+func byteArray(values []interface{}) ([]byte, bool) {
+	b := make([]byte, len(values))
+	for i, v := range values {
+		f, ok := v.(float64)
+		if !ok || f < 0 || f > 255 || f != float64(int(f)) {
+			return nil, false
+		}
+		b[i] = byte(f)
+	}
+	return b, true
+}
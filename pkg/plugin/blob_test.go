@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestResultToFrame_BlobModes(t *testing.T) {
+	png := []interface{}{137.0, 80.0, 78.0, 71.0, 13.0, 10.0, 26.0, 10.0}
+	tests := []struct {
+		mode string
+		val  interface{}
+		want interface{}
+	}{
+		{"", []interface{}{104.0, 105.0}, "aGk="},
+		{blobModeBase64, "test", "dGVzdA=="},
+		{blobModeHex, []interface{}{0.0, 255.0}, "00ff"},
+		{blobModeHex, "abcd", "61626364"},
+		{blobModeUTF8, []interface{}{104.0, 255.0, 105.0}, "h�i"},
+		{blobModeUTF8, "test", "test"},
+		{blobModeLength, []interface{}{104.0, 105.0}, int64(2)},
+		{blobModeLength, "abcd", int64(4)},
+		{blobModeDataURI, png, "data:image/png;base64,iVBORw0KGgo="},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.mode, tt.val), func(t *testing.T) {
+			result := &RqliteResult{
+				Columns: []string{"data"},
+				Types:   []string{"BLOB"},
+				Values:  [][]interface{}{{tt.val}, {nil}},
+			}
+			frame, err := ResultToFrame(result, FrameOptions{BlobMode: tt.mode})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			field := frame.Fields[0]
+			if got, _ := field.ConcreteAt(0); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if _, ok := field.ConcreteAt(1); ok {
+				t.Error("expected NULL to stay null")
+			}
+		})
+	}
+}
+
+func TestResultToFrame_BlobColumns(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"thumb", "payload", "name"},
+		Types:   []string{"blob", "blob", "text"},
+		Values:  [][]interface{}{{[]interface{}{104.0, 105.0}, []interface{}{104.0, 105.0}, []interface{}{104.0, 105.0}}},
+	}
+	frame, err := ResultToFrame(result, FrameOptions{BlobMode: blobModeHex, BlobColumns: map[string]string{"THUMB": blobModeLength}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if frame.Fields[0].Type() != data.FieldTypeNullableInt64 {
+		t.Errorf("expected a length field, got %v", frame.Fields[0].Type())
+	}
+	if got, _ := frame.Fields[1].ConcreteAt(0); got != "6869" {
+		t.Errorf("expected the query's mode for other columns, got %v", got)
+	}
+	if got, _ := frame.Fields[2].ConcreteAt(0); got != "aGk=" {
+		t.Errorf("expected byte arrays of untyped columns as base64, got %v", got)
+	}
+}
+
+func TestDecodeQueryFrames_Blob(t *testing.T) {
+	body := `{"results":[{"columns":["data"],"types":["blob"],"values":[[[1,2,3]],["\u0001\u0002\u0003"]]}]}`
+	resp, err := decodeQueryFrames(context.Background(), strings.NewReader(body), ResultLimits{}, FrameOptions{BlobMode: blobModeHex})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	field := resp.Results[0].Frame.Fields[0]
+	for i := 0; i < field.Len(); i++ {
+		if got, _ := field.ConcreteAt(i); got != "010203" {
+			t.Errorf("row %d: got %v, want 010203", i, got)
+		}
+	}
+}
+
+func TestFrameOptions_Validate(t *testing.T) {
+	if err := (FrameOptions{BlobMode: "hex", BlobColumns: map[string]string{"a": "datauri"}}).validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (FrameOptions{BlobMode: "binary"}).validate(); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+	if err := (FrameOptions{BlobColumns: map[string]string{"a": "raw"}}).validate(); err == nil {
+		t.Error("expected an error for an unknown column mode")
+	}
+}
@@ -0,0 +1,171 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultBreakerErrorRate = 0.5
+	defaultBreakerCooldown  = 30 * time.Second
+	defaultMaxConcurrent    = 10
+
+	// breakerWindow is the number of recent queries the error rate is
+	// computed over, breakerMinQueries how many of them are needed before
+	// the breaker can open.
+	breakerWindow     = 20
+	breakerMinQueries = 10
+)
+
+// errUnavailable is returned without contacting rqlite while the circuit
+// breaker is open.
+var errUnavailable = errors.New("rqlite unavailable")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// BreakerSettings configures a CircuitBreaker.
+type BreakerSettings struct {
+	ErrorRate float64       // failed share of recent queries that opens the breaker
+	Latency   time.Duration // queries slower than this count as failed, 0 to ignore latency
+	Cooldown  time.Duration // how long the breaker stays open before probing
+}
+
+// CircuitBreaker stops sending queries to rqlite once too many recent
+// queries failed or were slow. After the cooldown a single probe query is let
+// through: if it succeeds the breaker closes, otherwise it opens again.
+type CircuitBreaker struct {
+	settings BreakerSettings
+
+	mu       sync.Mutex
+	state    breakerState
+	openedAt time.Time
+	probing  bool
+	outcomes [breakerWindow]bool // true for failed queries, used as a ring
+	count    int
+	next     int
+	now      func() time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker.
+func NewCircuitBreaker(settings BreakerSettings) *CircuitBreaker {
+	return &CircuitBreaker{settings: settings, now: time.Now}
+}
+
+// allow reports whether a query may be sent, returning an error wrapping
+// errUnavailable if not.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if wait := b.openedAt.Add(b.settings.Cooldown).Sub(b.now()); wait > 0 {
+			return fmt.Errorf("%w: too many failed queries, retrying in %s", errUnavailable, wait.Round(time.Second))
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w: waiting for a probe query to succeed", errUnavailable)
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record registers the outcome of a query let through by allow.
+func (b *CircuitBreaker) record(failed bool, latency time.Duration) {
+	if b.settings.Latency > 0 && latency > b.settings.Latency {
+		failed = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.probing = false
+		if failed {
+			b.open()
+		} else {
+			b.state = breakerClosed
+			b.count, b.next = 0, 0
+		}
+		return
+	}
+
+	b.outcomes[b.next] = failed
+	b.next = (b.next + 1) % breakerWindow
+	if b.count < breakerWindow {
+		b.count++
+	}
+
+	if b.state == breakerClosed && b.count >= breakerMinQueries {
+		failures := 0
+		for i := 0; i < b.count; i++ {
+			if b.outcomes[i] {
+				failures++
+			}
+		}
+		if float64(failures)/float64(b.count) >= b.settings.ErrorRate {
+			b.open()
+		}
+	}
+}
+
+// release gives up a probe slot without recording an outcome, for queries
+// canceled by the caller.
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.probing = false
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.state = breakerOpen
+	b.openedAt = b.now()
+	b.count, b.next = 0, 0
+}
+
+// isFailure reports whether a query error indicates that rqlite is
+// unhealthy, as opposed to a problem with the query itself.
+func isFailure(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500
+	}
+	if errors.Is(err, errLeadershipChange) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// acquire waits for a free query slot of the client, if it limits concurrent
+// queries, and returns a function to release it.
+func (c *RqliteClient) acquire(ctx context.Context) (func(), error) {
+	if c.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.slots <- struct{}{}:
+		return func() { <-c.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(settings BreakerSettings) (*CircuitBreaker, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	b := NewCircuitBreaker(settings)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b, now := newTestBreaker(BreakerSettings{ErrorRate: 0.5, Cooldown: 30 * time.Second})
+
+	for i := 0; i < breakerMinQueries; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("query %d: unexpected error: %v", i, err)
+		}
+		b.record(i%2 == 0, time.Millisecond)
+	}
+
+	err := b.allow()
+	if !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected the breaker to be open, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "retrying in 30s") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// After the cooldown a single probe is let through.
+	*now = now.Add(31 * time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected a probe, got %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected a single probe, got %v", err)
+	}
+
+	// A failed probe opens the breaker again.
+	b.record(true, time.Millisecond)
+	if err := b.allow(); !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected the breaker to open again, got %v", err)
+	}
+
+	// A successful probe closes it.
+	*now = now.Add(31 * time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected a probe, got %v", err)
+	}
+	b.record(false, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("expected the breaker to be closed, got %v", err)
+		}
+	}
+}
+
+func TestCircuitBreaker_Latency(t *testing.T) {
+	b, _ := newTestBreaker(BreakerSettings{ErrorRate: 0.5, Latency: time.Second, Cooldown: time.Minute})
+	for i := 0; i < breakerMinQueries; i++ {
+		b.record(false, 2*time.Second)
+	}
+	if err := b.allow(); !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected slow queries to open the breaker, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_ReleaseProbe(t *testing.T) {
+	b, now := newTestBreaker(BreakerSettings{ErrorRate: 0.5, Cooldown: time.Second})
+	b.open()
+	*now = now.Add(2 * time.Second)
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected a probe, got %v", err)
+	}
+	b.release()
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected a new probe after a canceled one, got %v", err)
+	}
+}
+
+func TestRqliteClient_CircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := &RqliteClient{
+		httpClient:       server.Client(),
+		baseURL:          server.URL,
+		consistencyLevel: "weak",
+		breaker:          NewCircuitBreaker(BreakerSettings{ErrorRate: 0.5, Cooldown: time.Minute}),
+	}
+	for i := 0; i < breakerMinQueries; i++ {
+		_, _ = client.Query(context.Background(), "SELECT 1")
+	}
+
+	_, err := client.Query(context.Background(), "SELECT 1")
+	if !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected rqlite unavailable, got %v", err)
+	}
+	if calls.Load() != breakerMinQueries {
+		t.Errorf("expected %d calls, got %d", breakerMinQueries, calls.Load())
+	}
+}
+
+func TestRqliteClient_ConcurrencyLimit(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"results":[{}]}`))
+	}))
+	defer server.Close()
+
+	client := &RqliteClient{
+		httpClient:       server.Client(),
+		baseURL:          server.URL,
+		consistencyLevel: "weak",
+		slots:            make(chan struct{}, 2),
+	}
+
+	done := make(chan error)
+	for i := 0; i < 6; i++ {
+		go func() {
+			_, err := client.Query(context.Background(), "SELECT 1")
+			done <- err
+		}()
+	}
+	for i := 0; i < 6; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if maxInFlight.Load() > 2 {
+		t.Errorf("expected at most 2 queries in flight, got %d", maxInFlight.Load())
+	}
+
+	// A query waiting for a slot gives up with its context.
+	client.slots <- struct{}{}
+	client.slots <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.Query(ctx, "SELECT 1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
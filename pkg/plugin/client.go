@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,6 +11,8 @@ import (
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // RqliteClient wraps HTTP communication with a rqlite cluster.
@@ -19,6 +20,11 @@ type RqliteClient struct {
 	httpClient       *http.Client
 	baseURL          string
 	consistencyLevel string
+	limits           ResultLimits
+	retry            RetryPolicy
+	breaker          *CircuitBreaker // nil to disable
+	slots            chan struct{}   // bounds concurrent queries, nil for no limit
+	metrics          *Metrics
 }
 
 // NewRqliteClient creates a new RqliteClient using Grafana's HTTP client provider.
@@ -47,41 +53,63 @@ func NewRqliteClient(baseURL, consistencyLevel string, opts ...httpclient.Option
 }
 
 // Query executes a SQL query against rqlite and returns the response.
-func (c *RqliteClient) Query(ctx context.Context, sql string) (*RqliteQueryResponse, error) {
-	body, err := json.Marshal([]string{sql})
+// Any args are sent as positional parameters for the statement's ? placeholders.
+func (c *RqliteClient) Query(ctx context.Context, sql string, args ...interface{}) (*RqliteQueryResponse, error) {
+	return c.execute(ctx, "RqliteClient.Query", sql, args, func(body io.Reader) (*RqliteQueryResponse, error) {
+		return decodeQueryResponse(ctx, body, c.limits)
+	})
+}
+
+// QueryFrame executes a SQL query like Query, but decodes the rows of each
+// result directly into RqliteResult.Frame while the response is read, so the
+// rows are never held as generic JSON values.
+func (c *RqliteClient) QueryFrame(ctx context.Context, sql string, opts FrameOptions, args ...interface{}) (*RqliteQueryResponse, error) {
+	return c.execute(ctx, "RqliteClient.QueryFrame", sql, args, func(body io.Reader) (*RqliteQueryResponse, error) {
+		return decodeQueryFrames(ctx, body, c.limits, opts)
+	})
+}
+
+// query sends a statement to rqlite and returns the body of a successful
+// response, which the caller must close.
+func (c *RqliteClient) query(ctx context.Context, sql string, args []interface{}) (io.ReadCloser, error) {
+	var statement interface{} = sql
+	if len(args) > 0 {
+		statement = append([]interface{}{sql}, args...)
+	}
+
+	body, err := json.Marshal(RqliteQueryRequest{statement})
 	if err != nil {
 		return nil, fmt.Errorf("marshaling query: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/db/query?level=%s", c.baseURL, c.consistencyLevel)
+	// With blob_array, BLOB values are byte arrays and cannot be mistaken for
+	// text stored in BLOB columns.
+	url := fmt.Sprintf("%s/db/query?level=%s&timings&blob_array", c.baseURL, c.consistencyLevel)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if creds, ok := credentialsFromContext(ctx); ok {
+		// Set before the HTTP client's middleware, which keeps an existing
+		// Authorization header instead of adding the shared credentials.
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("executing query: %w", err)
 	}
-	defer func() { _ = resp.Body.Close() }()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
-	}
 
 	if resp.StatusCode != http.StatusOK {
+		defer func() { _ = resp.Body.Close() }()
+		respBody, _ := io.ReadAll(resp.Body)
 		log.DefaultLogger.Error("rqlite query returned non-OK status", "status", resp.StatusCode, "body", string(respBody))
-		return nil, errors.New(genericQueryErrorMessage)
-	}
-
-	var result RqliteQueryResponse
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return nil, fmt.Errorf("unmarshaling response: %w", err)
+		return nil, &statusError{StatusCode: resp.StatusCode}
 	}
 
-	return &result, nil
+	return resp.Body, nil
 }
 
 // CheckReady checks if the rqlite node is ready.
@@ -25,6 +25,12 @@ func TestRqliteClient_Query(t *testing.T) {
 		if level != "strong" {
 			t.Errorf("expected level=strong, got %s", level)
 		}
+		if !r.URL.Query().Has("timings") {
+			t.Errorf("expected timings parameter, got %s", r.URL.RawQuery)
+		}
+		if !r.URL.Query().Has("blob_array") {
+			t.Errorf("expected blob_array parameter, got %s", r.URL.RawQuery)
+		}
 
 		// Verify content type
 		if r.Header.Get("Content-Type") != "application/json" {
@@ -72,6 +78,9 @@ func TestRqliteClient_Query(t *testing.T) {
 	if len(result.Results[0].Columns) != 1 {
 		t.Errorf("expected 1 column, got %d", len(result.Results[0].Columns))
 	}
+	if result.BytesReceived == 0 {
+		t.Error("expected bytes received to be recorded")
+	}
 }
 
 func TestRqliteClient_CheckReady(t *testing.T) {
@@ -139,3 +148,25 @@ func TestRqliteClient_Query_HTTPError(t *testing.T) {
 		t.Fatalf("expected generic error %q, got %q", genericQueryErrorMessage, err.Error())
 	}
 }
+
+func TestRqliteClient_Query_Parameterized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		expected := `[["SELECT * FROM t WHERE name = ? AND ts = ?","web-1",1000]]`
+		if string(body) != expected {
+			t.Errorf("expected body %s, got %s", expected, body)
+		}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{{}}})
+	}))
+	defer server.Close()
+
+	client := &RqliteClient{
+		httpClient:       server.Client(),
+		baseURL:          server.URL,
+		consistencyLevel: "weak",
+	}
+
+	if _, err := client.Query(context.Background(), "SELECT * FROM t WHERE name = ? AND ts = ?", "web-1", 1000); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
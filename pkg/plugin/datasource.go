@@ -3,14 +3,21 @@ package plugin
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -22,6 +29,7 @@ var (
 	_ backend.QueryDataHandler      = (*Datasource)(nil)
 	_ backend.CheckHealthHandler    = (*Datasource)(nil)
 	_ backend.CallResourceHandler   = (*Datasource)(nil)
+	_ backend.StreamHandler         = (*Datasource)(nil)
 	_ instancemgmt.InstanceDisposer = (*Datasource)(nil)
 )
 
@@ -30,6 +38,17 @@ type Datasource struct {
 	client          *RqliteClient
 	resourceHandler backend.CallResourceHandler
 	settings        PluginSettings
+	uid             string
+	metrics         *Metrics
+	audit           *auditLog
+	identities      *IdentityMap // nil if identity forwarding is disabled
+	tables          *tableAccess
+
+	chunkCache *chunkCache
+
+	liveInterval time.Duration
+	liveMu       sync.Mutex
+	liveQueries  map[string]*liveQuery
 }
 
 // NewDatasource creates a new datasource instance.
@@ -48,10 +67,74 @@ func NewDatasource(ctx context.Context, settings backend.DataSourceInstanceSetti
 	if err != nil {
 		return nil, fmt.Errorf("creating rqlite client: %w", err)
 	}
+	metrics := NewMetrics(settings.UID)
+	client.metrics = metrics
+	client.limits = ResultLimits{
+		MaxRows:  pluginSettings.MaxRows,
+		MaxBytes: pluginSettings.MaxResponseBytes,
+		Reject:   pluginSettings.LimitAction == "reject",
+	}
+	client.retry = RetryPolicy{
+		MaxRetries: pluginSettings.MaxRetries,
+		BaseDelay:  defaultRetryBaseDelay,
+		MaxDelay:   defaultRetryMaxDelay,
+	}
+	switch {
+	case pluginSettings.MaxRetries == 0:
+		client.retry.MaxRetries = defaultMaxRetries
+	case pluginSettings.MaxRetries < 0:
+		client.retry.MaxRetries = 0
+	}
+
+	if pluginSettings.BreakerErrorRate >= 0 {
+		breaker := BreakerSettings{ErrorRate: pluginSettings.BreakerErrorRate}
+		if breaker.ErrorRate == 0 {
+			breaker.ErrorRate = defaultBreakerErrorRate
+		}
+		if breaker.Latency, err = parseDurationSetting("breaker latency", pluginSettings.BreakerLatency, 0); err != nil {
+			return nil, err
+		}
+		if breaker.Cooldown, err = parseDurationSetting("breaker cooldown", pluginSettings.BreakerCooldown, defaultBreakerCooldown); err != nil {
+			return nil, err
+		}
+		client.breaker = NewCircuitBreaker(breaker)
+	}
+	switch {
+	case pluginSettings.MaxConcurrentQueries == 0:
+		client.slots = make(chan struct{}, defaultMaxConcurrent)
+	case pluginSettings.MaxConcurrentQueries > 0:
+		client.slots = make(chan struct{}, pluginSettings.MaxConcurrentQueries)
+	}
+
+	liveInterval, err := parseDurationSetting("live interval", pluginSettings.LiveInterval, defaultLiveInterval)
+	if err != nil {
+		return nil, err
+	}
 
 	ds := &Datasource{
-		client:   client,
-		settings: pluginSettings,
+		client:       client,
+		settings:     pluginSettings,
+		uid:          settings.UID,
+		metrics:      metrics,
+		audit:        newAuditLog(pluginSettings, log.DefaultLogger),
+		liveInterval: liveInterval,
+		liveQueries:  make(map[string]*liveQuery),
+	}
+
+	if ds.tables, err = newTableAccess(pluginSettings.AllowedTables, pluginSettings.DeniedTables); err != nil {
+		return nil, err
+	}
+	if pluginSettings.IdentityForwarding {
+		if ds.identities, err = parseIdentityMap(settings.DecryptedSecureJSONData); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case pluginSettings.ChunkCacheSize == 0:
+		ds.chunkCache = newChunkCache(defaultChunkCacheSize)
+	case pluginSettings.ChunkCacheSize > 0:
+		ds.chunkCache = newChunkCache(pluginSettings.ChunkCacheSize)
 	}
 
 	mux := http.NewServeMux()
@@ -61,6 +144,19 @@ func NewDatasource(ctx context.Context, settings backend.DataSourceInstanceSetti
 	return ds, nil
 }
 
+// parseDurationSetting parses a positive duration setting, returning def if
+// the setting is empty.
+func parseDurationSetting(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return d, nil
+}
+
 // Dispose cleans up resources.
 func (d *Datasource) Dispose() {}
 
@@ -69,29 +165,84 @@ func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataReques
 	response := backend.NewQueryDataResponse()
 
 	for _, q := range req.Queries {
-		res := d.query(ctx, q)
+		res := d.query(ctx, req, q)
 		response.Responses[q.RefID] = res
 	}
 
 	return response, nil
 }
 
-func (d *Datasource) query(ctx context.Context, query backend.DataQuery) backend.DataResponse {
+func (d *Datasource) query(ctx context.Context, req *backend.QueryDataRequest, query backend.DataQuery) (res backend.DataResponse) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "Datasource.query", trace.WithAttributes(
+		attribute.String("query.ref_id", query.RefID),
+	))
+	kind, start := queryKindUnknown, time.Now()
+	var rawSQL string
+	var rows int
+	defer func() {
+		d.metrics.observeQuery(kind, start, res)
+		d.audit.record(req, query, rawSQL, rows, start, res)
+		if res.Error != nil {
+			_ = tracing.Error(span, res.Error)
+		}
+		span.End()
+	}()
+
 	var qm QueryModel
 	if err := json.Unmarshal(query.JSON, &qm); err != nil {
 		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("json unmarshal: %v", err))
 	}
+	kind = queryKind(req, query, qm)
+	ctx = withQueryKind(ctx, kind)
+	span.SetAttributes(attribute.String("query.kind", kind))
 
-	rawSQL := qm.RawSQL
-	if rawSQL == "" {
+	ctx, err := d.withUser(ctx, req.PluginContext)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusForbidden, err.Error())
+	}
+
+	if qm.RawSQL == "" {
 		return backend.ErrDataResponse(backend.StatusBadRequest, "query is empty")
 	}
+	if err := qm.frameOptions().validate(); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
 
-	// Apply macros
-	rawSQL = ApplyMacros(rawSQL, query.TimeRange, query.Interval.Milliseconds())
+	// Variable options are not narrowed by the dashboard's ad hoc filters.
+	if query.QueryType == queryTypeVariable {
+		qm.AdhocFilters = nil
+	}
 
-	// Execute query
-	result, err := d.client.Query(ctx, rawSQL)
+	rawSQL, args, err := d.expandQuery(ctx, &qm, query.TimeRange, query.Interval.Milliseconds())
+	if errors.Is(err, errTableAccess) {
+		return backend.ErrDataResponse(backend.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	chunks, err := queryChunks(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	// Execute query. Variable and live queries need the raw values,
+	// everything else is decoded straight into a frame.
+	var result *RqliteQueryResponse
+	switch {
+	case query.QueryType == queryTypeVariable || qm.Live:
+		result, err = d.client.Query(ctx, rawSQL, args...)
+	case chunks != nil:
+		result, err = d.querySplit(ctx, qm, query, chunks)
+	default:
+		result, err = d.client.QueryFrame(ctx, rawSQL, qm.frameOptions(), args...)
+	}
+	if errors.Is(err, errResultLimit) {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	if errors.Is(err, errUnavailable) {
+		return backend.ErrDataResponse(backend.StatusBadGateway, err.Error())
+	}
 	if err != nil {
 		log.DefaultLogger.Error("Failed to execute query", "error", err, "refID", query.RefID)
 		return backend.ErrDataResponse(backend.StatusInternal, genericQueryErrorMessage)
@@ -100,20 +251,166 @@ func (d *Datasource) query(ctx context.Context, query backend.DataQuery) backend
 	if len(result.Results) == 0 {
 		return backend.DataResponse{}
 	}
+	rows = result.Results[0].RowCount
+	span.SetAttributes(attribute.Int("rqlite.rows", rows))
+
+	_, convertSpan := tracing.DefaultTracer().Start(ctx, "Datasource.convertFrames")
+	defer convertSpan.End()
+
+	var frames data.Frames
+	if query.QueryType == queryTypeVariable {
+		frame, err := VariableFrame(&result.Results[0], qm.VariableRegex, qm.VariableSort)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+		return backend.DataResponse{Frames: withQueryMeta(data.Frames{frame}, rawSQL, args, result)}
+	}
 
 	// Convert to data frame
-	frame, err := ResultToFrame(&result.Results[0], qm.TimeColumns)
+	frame, err := ResultToFrame(&result.Results[0], qm.frameOptions())
 	if err != nil {
 		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("converting result: %v", err))
 	}
 
-	if qm.Format == "time_series" {
-		frame.Meta = &data.FrameMeta{
-			Type: data.FrameTypeTimeSeriesWide,
+	var channel string
+	if qm.Live {
+		// Streamed rows must keep the fields of the initial result, so the
+		// detected time columns are listed for the stream.
+		if qm.frameOptions().detectsTimeColumns() {
+			qm.TimeColumns = timeFieldColumns(frame)
+			qm.DetectTimeColumns = false
+		}
+		channel, err = d.registerLive(ctx, qm, query, &result.Results[0])
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+	}
+
+	switch {
+	case qm.Format == "time_series":
+		frame, err = ToTimeSeriesFrame(frame)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("converting to time series: %v", err))
+		}
+		// Live frames are appended to by the stream, so they keep their shape.
+		downsample := qm.Downsample
+		if qm.Live {
+			downsample = ""
+		}
+		frames, err = DownsampleFrame(frame, query.MaxDataPoints, downsample)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+	case isAlertRequest(req) && frame.TimeSeriesSchema().Type == data.TimeSeriesTypeNot:
+		frames, err = ToNumericMultiFrames(frame)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+	default:
+		frames = data.Frames{frame}
+	}
+
+	frames = withQueryMeta(frames, rawSQL, args, result)
+	for _, frame := range frames {
+		frame.Meta.Channel = channel
+	}
+	return backend.DataResponse{Frames: frames}
+}
+
+// expandQuery applies macros and the applicable ad hoc filters to the query's
+// SQL and returns the SQL to execute with its positional arguments. Queries on tables the
+// data source does not expose fail with an error wrapping errTableAccess.
+// The query's ad hoc filters are narrowed to the applicable ones on first
+// use, so later expansions of the same query model skip the lookup.
+func (d *Datasource) expandQuery(ctx context.Context, qm *QueryModel, timeRange backend.TimeRange, intervalMS int64) (string, []interface{}, error) {
+	return d.expandSQL(ctx, qm, ApplyMacros(qm.RawSQL, timeRange, intervalMS))
+}
+
+// expandSQL is expandQuery for the query's SQL with macros applied.
+func (d *Datasource) expandSQL(ctx context.Context, qm *QueryModel, sql string) (string, []interface{}, error) {
+	_, span := tracing.DefaultTracer().Start(ctx, "expandQuery")
+	defer span.End()
+
+	sql, err := applyTenantFilter(ctx, sql)
+	if err != nil {
+		return "", nil, tracing.Error(span, err)
+	}
+	if err := d.tables.check(sql); err != nil {
+		return "", nil, tracing.Error(span, err)
+	}
+	if !qm.adhocResolved {
+		filters, err := d.applicableAdhocFilters(ctx, sql, qm.AdhocFilters)
+		if err != nil {
+			return "", nil, tracing.Error(span, err)
+		}
+		qm.AdhocFilters, qm.adhocResolved = filters, true
+	}
+	sql, args, err := ApplyAdhocFilters(sql, qm.AdhocFilters)
+	if err != nil {
+		return "", nil, tracing.Error(span, err)
+	}
+	span.SetAttributes(
+		attribute.String("rqlite.statement_fingerprint", sqlFingerprint(sql)),
+		attribute.Int("rqlite.args", len(args)),
+	)
+	return sql, args, nil
+}
+
+// queryStats returns the execution stats shown in the query inspector.
+func queryStats(resp *RqliteQueryResponse) []data.QueryStat {
+	result := resp.Results[0]
+	stats := []data.QueryStat{
+		{FieldConfig: data.FieldConfig{DisplayName: "Server time", Unit: "s"}, Value: result.Time},
+		{FieldConfig: data.FieldConfig{DisplayName: "Rows"}, Value: float64(result.RowCount)},
+		{FieldConfig: data.FieldConfig{DisplayName: "Bytes received", Unit: "decbytes"}, Value: float64(resp.BytesReceived)},
+		{FieldConfig: data.FieldConfig{DisplayName: "Retries"}, Value: float64(resp.Retries)},
+	}
+	if resp.Chunks > 0 {
+		stats = append(stats,
+			data.QueryStat{FieldConfig: data.FieldConfig{DisplayName: "Chunks"}, Value: float64(resp.Chunks)},
+			data.QueryStat{FieldConfig: data.FieldConfig{DisplayName: "Cached chunks"}, Value: float64(resp.CachedChunks)},
+		)
+	}
+	return stats
+}
+
+// withQueryMeta records the executed SQL, its arguments and execution stats on every frame,
+// and warns if the result was truncated at a configured limit.
+func withQueryMeta(frames data.Frames, sql string, args []interface{}, resp *RqliteQueryResponse) data.Frames {
+	stats := queryStats(resp)
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
 		}
+		frame.Meta.ExecutedQueryString = executedQuery(sql, args)
+		frame.Meta.Stats = append(frame.Meta.Stats, stats...)
+		if resp.Truncated != "" {
+			frame.AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     fmt.Sprintf("Result truncated at the %s, refine the query to see all rows", resp.Truncated),
+			})
+		}
+	}
+	return frames
+}
+
+// executedQuery returns the SQL with its bound arguments appended as a trailing
+// comment, so the query inspector shows the values the placeholders stood for.
+func executedQuery(sql string, args []interface{}) string {
+	if len(args) == 0 {
+		return sql
+	}
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return sql
 	}
+	// A "*/" inside a string argument would end the comment early.
+	return fmt.Sprintf("%s\n/* args: %s */", sql, strings.ReplaceAll(string(encoded), "*/", "*\\/"))
+}
 
-	return backend.DataResponse{Frames: data.Frames{frame}}
+// isAlertRequest reports whether the request was issued by Grafana alerting.
+func isAlertRequest(req *backend.QueryDataRequest) bool {
+	return req.Headers["FromAlert"] == "true"
 }
 
 // CheckHealth handles health checks.
@@ -134,5 +431,17 @@ func (d *Datasource) CheckHealth(ctx context.Context, _ *backend.CheckHealthRequ
 
 // CallResource handles resource calls for the visual query builder.
 func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
-	return d.resourceHandler.CallResource(ctx, req, sender)
+	status := http.StatusOK
+	defer func() { d.metrics.observeResourceCall(req.Path, status) }()
+
+	ctx = withQueryKind(ctx, queryKindResource)
+	ctx, err := d.withUser(ctx, req.PluginContext)
+	if err != nil {
+		status = http.StatusForbidden
+		return sender.Send(&backend.CallResourceResponse{Status: status, Body: []byte(err.Error())})
+	}
+	return d.resourceHandler.CallResource(ctx, req, backend.CallResourceResponseSenderFunc(func(resp *backend.CallResourceResponse) error {
+		status = resp.Status
+		return sender.Send(resp)
+	}))
 }
@@ -3,13 +3,16 @@ package plugin
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
 func TestDatasource_QueryData(t *testing.T) {
@@ -23,6 +26,7 @@ func TestDatasource_QueryData(t *testing.T) {
 						{float64(1700000000), float64(42.5)},
 						{float64(1700000060), float64(43.1)},
 					},
+					Time: 0.0025,
 				},
 			},
 		}
@@ -42,7 +46,7 @@ func TestDatasource_QueryData(t *testing.T) {
 	qm := QueryModel{
 		RawSQL:      "SELECT time, value FROM metrics WHERE $__timeFilter(time)",
 		Format:      "time_series",
-		TimeColumns: []string{"time"},
+		TimeColumns: []TimeColumn{{Name: "time"}},
 	}
 	qmJSON, _ := json.Marshal(qm)
 
@@ -85,6 +89,274 @@ func TestDatasource_QueryData(t *testing.T) {
 	if frame.Fields[0].Len() != 2 {
 		t.Errorf("expected 2 rows, got %d", frame.Fields[0].Len())
 	}
+
+	expectedSQL := "SELECT time, value FROM metrics WHERE time >= 1700000000 AND time <= 1700000120"
+	if frame.Meta.ExecutedQueryString != expectedSQL {
+		t.Errorf("expected executed query %q, got %q", expectedSQL, frame.Meta.ExecutedQueryString)
+	}
+
+	stats := make(map[string]float64, len(frame.Meta.Stats))
+	for _, stat := range frame.Meta.Stats {
+		stats[stat.DisplayName] = stat.Value
+	}
+	if stats["Server time"] != 0.0025 {
+		t.Errorf("expected server time 0.0025, got %v", stats["Server time"])
+	}
+	if stats["Rows"] != 2 {
+		t.Errorf("expected 2 rows stat, got %v", stats["Rows"])
+	}
+	if stats["Bytes received"] <= 0 {
+		t.Errorf("expected bytes received stat, got %v", stats["Bytes received"])
+	}
+}
+
+func TestDatasource_QueryData_AdhocFilters(t *testing.T) {
+	var bodies []string
+	rqliteServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		resp := RqliteQueryResponse{
+			Results: []RqliteResult{
+				{
+					Columns: []string{"host", "value"},
+					Types:   []string{"text", "real"},
+					Values:  [][]interface{}{{"web-1", float64(42.5)}},
+				},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer rqliteServer.Close()
+
+	ds := &Datasource{
+		client: &RqliteClient{
+			httpClient:       rqliteServer.Client(),
+			baseURL:          rqliteServer.URL,
+			consistencyLevel: "weak",
+		},
+	}
+
+	queryWith := func(queryType string, filters ...AdhocFilter) backend.DataResponse {
+		bodies = nil
+		qmJSON, _ := json.Marshal(QueryModel{RawSQL: "SELECT host, value FROM cpu -- by host", AdhocFilters: filters})
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", QueryType: queryType, JSON: qmJSON}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return resp.Responses["A"]
+	}
+
+	resA := queryWith("", AdhocFilter{Key: "host", Operator: "=", Value: "web-1"}, AdhocFilter{Key: "dc", Operator: "=", Value: "eu"})
+	if resA.Error != nil {
+		t.Fatalf("unexpected error in response: %v", resA.Error)
+	}
+	if len(resA.Frames) != 1 || resA.Frames[0].Rows() != 1 {
+		t.Fatalf("expected 1 frame with 1 row, got %v", resA.Frames)
+	}
+	want := []string{
+		`["SELECT * FROM (SELECT host, value FROM cpu) LIMIT 0"]`,
+		`[["SELECT * FROM (SELECT host, value FROM cpu) WHERE (\"host\" = ?)","web-1"]]`,
+	}
+	if !reflect.DeepEqual(bodies, want) {
+		t.Errorf("expected the filter on the result column only\ngot  %s\nwant %s", bodies, want)
+	}
+	wantExecuted := "SELECT * FROM (SELECT host, value FROM cpu) WHERE (\"host\" = ?)\n/* args: [\"web-1\"] */"
+	if got := resA.Frames[0].Meta.ExecutedQueryString; got != wantExecuted {
+		t.Errorf("expected the executed query with its arguments\ngot  %q\nwant %q", got, wantExecuted)
+	}
+
+	queryWith("", AdhocFilter{Key: "dc", Operator: "=", Value: "eu"})
+	if want := `["SELECT host, value FROM cpu -- by host"]`; len(bodies) != 2 || bodies[1] != want {
+		t.Errorf("expected the query unchanged without applicable filters, got %s", bodies)
+	}
+
+	queryWith(queryTypeVariable, AdhocFilter{Key: "host", Operator: "=", Value: "web-1"})
+	if len(bodies) != 1 || strings.Contains(bodies[0], "WHERE") {
+		t.Errorf("expected variable queries to ignore ad hoc filters, got %s", bodies)
+	}
+}
+
+func TestExecutedQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		args []interface{}
+		want string
+	}{
+		{"no args", "SELECT 1", nil, "SELECT 1"},
+		{"args", "SELECT * FROM t WHERE a = ? AND b > ?", []interface{}{"x", 1.5}, "SELECT * FROM t WHERE a = ? AND b > ?\n/* args: [\"x\",1.5] */"},
+		{"trailing comment", "SELECT * FROM t WHERE a = ? -- note", []interface{}{"x"}, "SELECT * FROM t WHERE a = ? -- note\n/* args: [\"x\"] */"},
+		{"comment end in arg", "SELECT * FROM t WHERE a = ?", []interface{}{"*/"}, "SELECT * FROM t WHERE a = ?\n/* args: [\"*\\/\"] */"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := executedQuery(tt.sql, tt.args); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDatasource_QueryData_VariableQuery(t *testing.T) {
+	rqliteServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := RqliteQueryResponse{
+			Results: []RqliteResult{
+				{
+					Columns: []string{"__text", "__value"},
+					Types:   []string{"text", "integer"},
+					Values:  [][]interface{}{{"web-2", float64(2)}, {"web-1", float64(1)}, {"db-1", float64(3)}},
+				},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer rqliteServer.Close()
+
+	ds := &Datasource{
+		client: &RqliteClient{
+			httpClient:       rqliteServer.Client(),
+			baseURL:          rqliteServer.URL,
+			consistencyLevel: "weak",
+		},
+	}
+
+	qm := QueryModel{
+		RawSQL:        "SELECT name AS __text, id AS __value FROM hosts",
+		VariableRegex: "^web",
+		VariableSort:  "alphabetical-asc",
+	}
+	qmJSON, _ := json.Marshal(qm)
+
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", QueryType: queryTypeVariable, JSON: qmJSON}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resA := resp.Responses["A"]
+	if resA.Error != nil {
+		t.Fatalf("unexpected error in response: %v", resA.Error)
+	}
+	frame := resA.Frames[0]
+	if got := fieldStrings(t, frame, "__text"); !reflect.DeepEqual(got, []string{"web-1", "web-2"}) {
+		t.Errorf("unexpected texts: %v", got)
+	}
+	if got := fieldStrings(t, frame, "__value"); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("unexpected values: %v", got)
+	}
+}
+
+func TestDatasource_QueryData_AlertingNumericMulti(t *testing.T) {
+	rqliteServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := RqliteQueryResponse{
+			Results: []RqliteResult{
+				{
+					Columns: []string{"host", "cpu"},
+					Types:   []string{"text", "real"},
+					Values:  [][]interface{}{{"web-1", float64(10)}, {"web-2", float64(95)}},
+				},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer rqliteServer.Close()
+
+	ds := &Datasource{
+		client: &RqliteClient{
+			httpClient:       rqliteServer.Client(),
+			baseURL:          rqliteServer.URL,
+			consistencyLevel: "weak",
+		},
+	}
+
+	qmJSON, _ := json.Marshal(QueryModel{RawSQL: "SELECT host, avg(cpu) AS cpu FROM metrics GROUP BY host", Format: "table"})
+
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Headers: map[string]string{"FromAlert": "true"},
+		Queries: []backend.DataQuery{{RefID: "A", JSON: qmJSON}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resA := resp.Responses["A"]
+	if resA.Error != nil {
+		t.Fatalf("unexpected error in response: %v", resA.Error)
+	}
+	if len(resA.Frames) != 2 {
+		t.Fatalf("expected one frame per host, got %d", len(resA.Frames))
+	}
+	if host := resA.Frames[1].Fields[0].Labels["host"]; host != "web-2" {
+		t.Errorf("expected host label web-2, got %q", host)
+	}
+}
+
+func TestDatasource_QueryData_ResultLimits(t *testing.T) {
+	rqliteServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := RqliteQueryResponse{
+			Results: []RqliteResult{
+				{
+					Columns: []string{"id"},
+					Types:   []string{"integer"},
+					Values:  [][]interface{}{{float64(1)}, {float64(2)}, {float64(3)}},
+				},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer rqliteServer.Close()
+
+	qmJSON, _ := json.Marshal(QueryModel{RawSQL: "SELECT id FROM t"})
+
+	tests := []struct {
+		name   string
+		reject bool
+	}{
+		{"truncate", false},
+		{"reject", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := &Datasource{
+				client: &RqliteClient{
+					httpClient:       rqliteServer.Client(),
+					baseURL:          rqliteServer.URL,
+					consistencyLevel: "weak",
+					limits:           ResultLimits{MaxRows: 2, Reject: tt.reject},
+				},
+			}
+
+			resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+				Queries: []backend.DataQuery{{RefID: "A", JSON: qmJSON}},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resA := resp.Responses["A"]
+
+			if tt.reject {
+				if resA.Error == nil || !strings.Contains(resA.Error.Error(), "more than 2 rows") {
+					t.Fatalf("expected row limit error, got %v", resA.Error)
+				}
+				return
+			}
+
+			if resA.Error != nil {
+				t.Fatalf("unexpected error in response: %v", resA.Error)
+			}
+			frame := resA.Frames[0]
+			if frame.Rows() != 2 {
+				t.Errorf("expected 2 rows, got %d", frame.Rows())
+			}
+			if len(frame.Meta.Notices) != 1 || frame.Meta.Notices[0].Severity != data.NoticeSeverityWarning {
+				t.Fatalf("expected truncation warning, got %+v", frame.Meta.Notices)
+			}
+		})
+	}
 }
 
 func TestDatasource_QueryData_EmptySQL(t *testing.T) {
@@ -0,0 +1,254 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errResultLimit is returned when a result exceeds a configured row or byte
+// limit and the datasource is set to reject such results.
+var errResultLimit = errors.New("result exceeds the configured limit")
+
+var (
+	// errByteLimit is returned by limitedReader once its limit is exhausted.
+	errByteLimit = errors.New("response byte limit reached")
+	// errRowLimit stops decoding once a result set reaches the row limit.
+	errRowLimit = errors.New("row limit reached")
+)
+
+// ResultLimits bounds the size of query results read from rqlite.
+type ResultLimits struct {
+	MaxRows  int64 // maximum rows per result set, 0 for no limit
+	MaxBytes int64 // maximum response body size, 0 for no limit
+	Reject   bool  // fail instead of truncating results that exceed a limit
+}
+
+// limitedReader counts the bytes read and fails with errByteLimit once more
+// than limit bytes would be read.
+type limitedReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.limit > 0 {
+		if l.n >= l.limit {
+			return 0, errByteLimit
+		}
+		if remaining := l.limit - l.n; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// ctxCheckRows is how many rows are decoded between context checks.
+const ctxCheckRows = 1024
+
+// decodeQueryResponse decodes a rqlite query response token by token, so that
+// reading can stop at the row or byte limit. Rows up to the limit are kept
+// and the response is marked as truncated. With limits.Reject, exceeding a
+// limit returns an error wrapping errResultLimit instead.
+func decodeQueryResponse(ctx context.Context, r io.Reader, limits ResultLimits) (*RqliteQueryResponse, error) {
+	return decodeResponse(ctx, r, limits, responseDecoder{maxRows: limits.MaxRows})
+}
+
+// decodeQueryFrames decodes a rqlite query response like decodeQueryResponse,
+// but appends the rows of each result straight to the typed fields of its
+// Frame instead of keeping them as generic values.
+func decodeQueryFrames(ctx context.Context, r io.Reader, limits ResultLimits, opts FrameOptions) (*RqliteQueryResponse, error) {
+	return decodeResponse(ctx, r, limits, responseDecoder{
+		maxRows:      limits.MaxRows,
+		frames:       true,
+		frameOptions: opts,
+	})
+}
+
+func decodeResponse(ctx context.Context, r io.Reader, limits ResultLimits, rd responseDecoder) (*RqliteQueryResponse, error) {
+	lr := &limitedReader{r: r, limit: limits.MaxBytes}
+	rd.ctx = ctx
+	rd.dec = json.NewDecoder(lr)
+	resp := &RqliteQueryResponse{}
+
+	err := rd.decodeResponseBody(resp)
+	resp.BytesReceived = lr.n
+
+	switch {
+	case errors.Is(err, errByteLimit):
+		if limits.Reject {
+			return nil, fmt.Errorf("%w: more than %d bytes", errResultLimit, limits.MaxBytes)
+		}
+		resp.Truncated = fmt.Sprintf("response size limit of %d bytes", limits.MaxBytes)
+	case errors.Is(err, errRowLimit):
+		if limits.Reject {
+			return nil, fmt.Errorf("%w: more than %d rows", errResultLimit, limits.MaxRows)
+		}
+		resp.Truncated = fmt.Sprintf("row limit of %d rows", limits.MaxRows)
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case err != nil:
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+
+	return resp, nil
+}
+
+// responseDecoder holds the state for decoding a single response body.
+type responseDecoder struct {
+	ctx          context.Context
+	dec          *json.Decoder
+	maxRows      int64
+	frames       bool
+	frameOptions FrameOptions
+}
+
+func (rd responseDecoder) decodeResponseBody(resp *RqliteQueryResponse) error {
+	return decodeObject(rd.dec, func(key string) error {
+		switch key {
+		case "results":
+			return decodeArray(rd.dec, func() error {
+				resp.Results = append(resp.Results, RqliteResult{})
+				return rd.decodeResult(&resp.Results[len(resp.Results)-1])
+			})
+		case "time":
+			return rd.dec.Decode(&resp.Time)
+		default:
+			return skipValue(rd.dec)
+		}
+	})
+}
+
+func (rd responseDecoder) decodeResult(result *RqliteResult) error {
+	dec := rd.dec
+	return decodeObject(dec, func(key string) error {
+		switch key {
+		case "columns":
+			return dec.Decode(&result.Columns)
+		case "types":
+			return dec.Decode(&result.Types)
+		case "values":
+			return rd.decodeValues(result)
+		case "error":
+			return dec.Decode(&result.Error)
+		case "time":
+			return dec.Decode(&result.Time)
+		default:
+			return skipValue(dec)
+		}
+	})
+}
+
+// decodeValues reads the rows of a result. When decoding into frames and the
+// columns are already known, each row is decoded into a reused buffer and
+// only appended to the fields once it was read completely, so a limit hit
+// mid-row never leaves fields of uneven length. Otherwise rows are kept in
+// result.Values.
+func (rd responseDecoder) decodeValues(result *RqliteResult) error {
+	var b *frameBuilder
+	if rd.frames && result.Columns != nil {
+		b = newFrameBuilder(result.Columns, result.Types, rd.frameOptions, 0)
+		result.Frame = b.frame
+	}
+
+	var row []interface{}
+	err := decodeArray(rd.dec, func() error {
+		if rd.maxRows > 0 && int64(result.RowCount) >= rd.maxRows {
+			return errRowLimit
+		}
+		if result.RowCount%ctxCheckRows == 0 {
+			if err := rd.ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		if b == nil {
+			row = nil
+		}
+		if err := rd.dec.Decode(&row); err != nil {
+			return err
+		}
+		if b != nil {
+			b.appendRow(row)
+		} else {
+			result.Values = append(result.Values, row)
+		}
+		result.RowCount++
+		return nil
+	})
+	if b != nil {
+		b.finish()
+	}
+	return err
+}
+
+// decodeObject reads a JSON object or null, calling fn for each key with the
+// decoder positioned at its value.
+func decodeObject(dec *json.Decoder, fn func(key string) error) error {
+	if null, err := expectDelimOrNull(dec, '{'); err != nil || null {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("unexpected object key %v", tok)
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// decodeArray reads a JSON array or null, calling fn with the decoder
+// positioned at each element.
+func decodeArray(dec *json.Decoder, fn func() error) error {
+	if null, err := expectDelimOrNull(dec, '['); err != nil || null {
+		return err
+	}
+	for dec.More() {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+func expectDelimOrNull(dec *json.Decoder, delim json.Delim) (bool, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if tok == nil {
+		return true, nil
+	}
+	if tok != delim {
+		return false, fmt.Errorf("expected %v, got %v", delim, tok)
+	}
+	return false, nil
+}
+
+func skipValue(dec *json.Decoder) error {
+	var v json.RawMessage
+	return dec.Decode(&v)
+}
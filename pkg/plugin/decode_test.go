@@ -0,0 +1,172 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func rowsResponse(n int) string {
+	rows := make([]string, n)
+	for i := range rows {
+		rows[i] = fmt.Sprintf(`[%d,"host-%d"]`, i, i)
+	}
+	return `{"results":[{"columns":["id","host"],"types":["integer","text"],"values":[` +
+		strings.Join(rows, ",") + `],"time":0.5,"extra":{"ignored":[1,2]}}],"time":0.75}`
+}
+
+func TestDecodeQueryResponse(t *testing.T) {
+	body := rowsResponse(3)
+	resp, err := decodeQueryResponse(context.Background(), strings.NewReader(body), ResultLimits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(resp.Results))
+	}
+	result := resp.Results[0]
+	if len(result.Columns) != 2 || len(result.Types) != 2 || len(result.Values) != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Values[2][1] != "host-2" || result.Values[2][0] != float64(2) {
+		t.Errorf("unexpected row: %v", result.Values[2])
+	}
+	if result.Time != 0.5 || resp.Time != 0.75 {
+		t.Errorf("unexpected timings: %v, %v", result.Time, resp.Time)
+	}
+	if resp.BytesReceived != int64(len(body)) {
+		t.Errorf("expected %d bytes received, got %d", len(body), resp.BytesReceived)
+	}
+	if resp.Truncated != "" {
+		t.Errorf("unexpected truncation: %q", resp.Truncated)
+	}
+}
+
+func TestDecodeQueryResponse_ErrorResult(t *testing.T) {
+	resp, err := decodeQueryResponse(context.Background(), strings.NewReader(`{"results":[{"error":"no such table: foo","values":null}]}`), ResultLimits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Results[0].Error != "no such table: foo" {
+		t.Errorf("unexpected result: %+v", resp.Results[0])
+	}
+}
+
+func TestDecodeQueryResponse_InvalidJSON(t *testing.T) {
+	if _, err := decodeQueryResponse(context.Background(), strings.NewReader(`{"results":[{"values":[[1,`), ResultLimits{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDecodeQueryResponse_RowLimit(t *testing.T) {
+	resp, err := decodeQueryResponse(context.Background(), strings.NewReader(rowsResponse(10)), ResultLimits{MaxRows: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Results[0].Values) != 4 {
+		t.Errorf("expected 4 rows, got %d", len(resp.Results[0].Values))
+	}
+	if resp.Truncated != "row limit of 4 rows" {
+		t.Errorf("unexpected truncation: %q", resp.Truncated)
+	}
+
+	_, err = decodeQueryResponse(context.Background(), strings.NewReader(rowsResponse(10)), ResultLimits{MaxRows: 4, Reject: true})
+	if !errors.Is(err, errResultLimit) {
+		t.Fatalf("expected result limit error, got %v", err)
+	}
+}
+
+func TestDecodeQueryResponse_ByteLimit(t *testing.T) {
+	body := rowsResponse(1000)
+
+	resp, err := decodeQueryResponse(context.Background(), strings.NewReader(body), ResultLimits{MaxBytes: 1024})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rows := len(resp.Results[0].Values)
+	if rows == 0 || rows >= 1000 {
+		t.Errorf("expected a partial result, got %d rows", rows)
+	}
+	if resp.BytesReceived > 1024 {
+		t.Errorf("read %d bytes past the limit", resp.BytesReceived)
+	}
+	if resp.Truncated != "response size limit of 1024 bytes" {
+		t.Errorf("unexpected truncation: %q", resp.Truncated)
+	}
+
+	_, err = decodeQueryResponse(context.Background(), strings.NewReader(body), ResultLimits{MaxBytes: 1024, Reject: true})
+	if !errors.Is(err, errResultLimit) {
+		t.Fatalf("expected result limit error, got %v", err)
+	}
+}
+
+func TestDecodeQueryFrames(t *testing.T) {
+	body := `{"results":[{"columns":["ts","id","host"],"types":["integer","integer","text"],` +
+		`"values":[[1700000000,1,"a"],[1700000060,2,null]]}]}`
+	resp, err := decodeQueryFrames(context.Background(), strings.NewReader(body), ResultLimits{}, FrameOptions{TimeColumns: []TimeColumn{{Name: "ts"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result := resp.Results[0]
+	if result.Values != nil {
+		t.Errorf("expected no generic values, got %v", result.Values)
+	}
+	if result.RowCount != 2 {
+		t.Errorf("expected 2 rows, got %d", result.RowCount)
+	}
+	frame := result.Frame
+	if frame == nil || frame.Rows() != 2 {
+		t.Fatalf("unexpected frame: %v", frame)
+	}
+	if frame.Fields[0].Type() != data.FieldTypeNullableTime || frame.Fields[1].Type() != data.FieldTypeNullableInt64 {
+		t.Errorf("unexpected field types: %v, %v", frame.Fields[0].Type(), frame.Fields[1].Type())
+	}
+	if v, ok := frame.Fields[2].ConcreteAt(1); ok {
+		t.Errorf("expected null host, got %v", v)
+	}
+
+	got, err := ResultToFrame(&result, FrameOptions{})
+	if err != nil || got != frame {
+		t.Errorf("expected ResultToFrame to return the decoded frame, got %v, %v", got, err)
+	}
+}
+
+func TestDecodeQueryFrames_Limits(t *testing.T) {
+	body := rowsResponse(1000)
+
+	resp, err := decodeQueryFrames(context.Background(), strings.NewReader(body), ResultLimits{MaxBytes: 1024}, FrameOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frame := resp.Results[0].Frame
+	if frame.Rows() == 0 || frame.Rows() >= 1000 || frame.Rows() != resp.Results[0].RowCount {
+		t.Errorf("expected a partial result, got %d rows", frame.Rows())
+	}
+	if _, err := frame.RowLen(); err != nil {
+		t.Errorf("fields have uneven lengths: %v", err)
+	}
+
+	resp, err = decodeQueryFrames(context.Background(), strings.NewReader(body), ResultLimits{MaxRows: 4}, FrameOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Results[0].Frame.Rows() != 4 || resp.Truncated != "row limit of 4 rows" {
+		t.Errorf("unexpected result: %d rows, truncated %q", resp.Results[0].Frame.Rows(), resp.Truncated)
+	}
+}
+
+func TestDecodeQueryFrames_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := decodeQueryFrames(ctx, strings.NewReader(rowsResponse(10)), ResultLimits{}, FrameOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Downsampling modes for QueryModel.Downsample.
+const (
+	downsampleLTTB   = "lttb"
+	downsampleMinMax = "minmax"
+)
+
+type point struct {
+	t time.Time
+	v float64
+}
+
+// DownsampleFrame reduces a wide time series frame to at most maxPoints points
+// per series. Each value field becomes its own time series frame, since the
+// selected points differ between series. Frames that are not time series or
+// that already fit are returned unchanged. A notice on each downsampled frame
+// records the original and reduced number of points.
+func DownsampleFrame(frame *data.Frame, maxPoints int64, mode string) (data.Frames, error) {
+	if mode == "" || maxPoints <= 0 || int64(frame.Rows()) <= maxPoints {
+		return data.Frames{frame}, nil
+	}
+
+	var sample func([]point, int) []point
+	switch mode {
+	case downsampleLTTB:
+		sample = lttb
+	case downsampleMinMax:
+		sample = minMaxBuckets
+	default:
+		return nil, fmt.Errorf("unsupported downsampling mode %q", mode)
+	}
+
+	schema := frame.TimeSeriesSchema()
+	if schema.Type != data.TimeSeriesTypeWide {
+		return data.Frames{frame}, nil
+	}
+	timeField := frame.Fields[schema.TimeIndex]
+	var notices []data.Notice
+	if frame.Meta != nil {
+		notices = frame.Meta.Notices
+	}
+
+	frames := make(data.Frames, 0, len(schema.ValueIndices))
+	for _, idx := range schema.ValueIndices {
+		field := frame.Fields[idx]
+		if !field.Type().Numeric() {
+			continue
+		}
+
+		points := make([]point, 0, field.Len())
+		for i := 0; i < field.Len(); i++ {
+			t, ok := timeField.ConcreteAt(i)
+			if !ok {
+				continue
+			}
+			v, err := field.NullableFloatAt(i)
+			if err != nil || v == nil {
+				continue
+			}
+			points = append(points, point{t: t.(time.Time), v: *v})
+		}
+
+		sampled := sample(points, int(maxPoints))
+		times := make([]time.Time, len(sampled))
+		values := make([]float64, len(sampled))
+		for i, p := range sampled {
+			times[i] = p.t
+			values[i] = p.v
+		}
+
+		valueField := data.NewField(field.Name, field.Labels, values)
+		valueField.Config = field.Config
+		series := data.NewFrame(frame.Name, data.NewField(timeField.Name, nil, times), valueField)
+		series.Meta = &data.FrameMeta{
+			Type: data.FrameTypeTimeSeriesMulti,
+			Notices: append(slices.Clip(notices), data.Notice{
+				Severity: data.NoticeSeverityInfo,
+				Text:     fmt.Sprintf("Downsampled %s from %d to %d points (%s, max data points %d)", field.Name, len(points), len(sampled), mode, maxPoints),
+			}),
+		}
+		frames = append(frames, series)
+	}
+
+	return frames, nil
+}
+
+// lttb selects threshold points with the Largest-Triangle-Three-Buckets
+// algorithm, which preserves the visual shape of a series.
+func lttb(points []point, threshold int) []point {
+	if threshold >= len(points) || threshold <= 0 {
+		return points
+	}
+	if threshold < 3 {
+		return []point{points[0], points[len(points)-1]}[:threshold]
+	}
+
+	sampled := make([]point, 0, threshold)
+	sampled = append(sampled, points[0])
+
+	every := float64(len(points)-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		// Average of the next bucket, the third triangle vertex.
+		avgStart := int(float64(i+1)*every) + 1
+		avgEnd := min(int(float64(i+2)*every)+1, len(points))
+		var avgX, avgY float64
+		for j := avgStart; j < avgEnd; j++ {
+			avgX += pointX(points[j])
+			avgY += points[j].v
+		}
+		n := float64(avgEnd - avgStart)
+		avgX /= n
+		avgY /= n
+
+		// Pick the point of the current bucket that forms the largest triangle.
+		rangeStart := int(float64(i)*every) + 1
+		rangeEnd := int(float64(i+1)*every) + 1
+		ax, ay := pointX(points[a]), points[a].v
+
+		maxArea := -1.0
+		next := rangeStart
+		for j := rangeStart; j < rangeEnd; j++ {
+			area := math.Abs((ax-avgX)*(points[j].v-ay) - (ax-pointX(points[j]))*(avgY-ay))
+			if area > maxArea {
+				maxArea = area
+				next = j
+			}
+		}
+
+		sampled = append(sampled, points[next])
+		a = next
+	}
+
+	return append(sampled, points[len(points)-1])
+}
+
+// minMaxBuckets splits points into threshold/2 buckets and keeps the minimum
+// and maximum of each, so that spikes survive downsampling.
+func minMaxBuckets(points []point, threshold int) []point {
+	if threshold >= len(points) || threshold <= 0 {
+		return points
+	}
+
+	buckets := max(threshold/2, 1)
+	size := (len(points) + buckets - 1) / buckets
+
+	sampled := make([]point, 0, threshold)
+	for start := 0; start < len(points); start += size {
+		end := min(start+size, len(points))
+
+		minIdx, maxIdx := start, start
+		for j := start; j < end; j++ {
+			if points[j].v < points[minIdx].v {
+				minIdx = j
+			}
+			if points[j].v > points[maxIdx].v {
+				maxIdx = j
+			}
+		}
+
+		switch {
+		case minIdx == maxIdx:
+			sampled = append(sampled, points[minIdx])
+		case minIdx < maxIdx:
+			sampled = append(sampled, points[minIdx], points[maxIdx])
+		default:
+			sampled = append(sampled, points[maxIdx], points[minIdx])
+		}
+	}
+
+	return sampled
+}
+
+func pointX(p point) float64 {
+	return float64(p.t.UnixNano())
+}
@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func seriesPoints(n int) []point {
+	points := make([]point, n)
+	for i := range points {
+		points[i] = point{t: time.Unix(int64(i), 0), v: math.Sin(float64(i) / 10)}
+	}
+	return points
+}
+
+func TestLTTB(t *testing.T) {
+	points := seriesPoints(1000)
+	points[500].v = 100 // spike
+
+	sampled := lttb(points, 50)
+	if len(sampled) != 50 {
+		t.Fatalf("expected 50 points, got %d", len(sampled))
+	}
+	if sampled[0] != points[0] || sampled[49] != points[999] {
+		t.Error("expected first and last points to be kept")
+	}
+
+	spike := false
+	for i, p := range sampled {
+		if p.v == 100 {
+			spike = true
+		}
+		if i > 0 && !p.t.After(sampled[i-1].t) {
+			t.Fatalf("points out of order at %d", i)
+		}
+	}
+	if !spike {
+		t.Error("expected spike to be kept")
+	}
+}
+
+func TestLTTB_BelowThreshold(t *testing.T) {
+	points := seriesPoints(10)
+	if got := lttb(points, 20); len(got) != 10 {
+		t.Errorf("expected all 10 points, got %d", len(got))
+	}
+}
+
+func TestMinMaxBuckets(t *testing.T) {
+	points := seriesPoints(1000)
+	points[123].v = -50
+	points[456].v = 50
+
+	sampled := minMaxBuckets(points, 100)
+	if len(sampled) > 100 {
+		t.Fatalf("expected at most 100 points, got %d", len(sampled))
+	}
+
+	var minV, maxV float64
+	for i, p := range sampled {
+		minV = math.Min(minV, p.v)
+		maxV = math.Max(maxV, p.v)
+		if i > 0 && !p.t.After(sampled[i-1].t) {
+			t.Fatalf("points out of order at %d", i)
+		}
+	}
+	if minV != -50 || maxV != 50 {
+		t.Errorf("expected extremes to be kept, got min %v max %v", minV, maxV)
+	}
+}
+
+func TestDownsampleFrame(t *testing.T) {
+	times := make([]time.Time, 1000)
+	cpu := make([]*float64, 1000)
+	mem := make([]*int64, 1000)
+	for i := range times {
+		times[i] = time.Unix(int64(i), 0)
+		c := float64(i % 7)
+		m := int64(i)
+		cpu[i] = &c
+		mem[i] = &m
+	}
+	cpu[3] = nil
+
+	frame := data.NewFrame("response",
+		data.NewField("time", nil, times),
+		data.NewField("cpu", data.Labels{"host": "web-1"}, cpu),
+		data.NewField("mem", nil, mem),
+	)
+
+	frames, err := DownsampleFrame(frame, 100, downsampleLTTB)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(frames) != 2 {
+		t.Fatalf("expected one frame per series, got %d", len(frames))
+	}
+	for _, f := range frames {
+		if f.Rows() != 100 {
+			t.Errorf("expected 100 rows, got %d", f.Rows())
+		}
+		if f.Meta == nil || len(f.Meta.Notices) != 1 {
+			t.Fatalf("expected downsampling notice, got %+v", f.Meta)
+		}
+	}
+	if frames[0].Fields[1].Labels["host"] != "web-1" {
+		t.Errorf("expected labels to be kept, got %v", frames[0].Fields[1].Labels)
+	}
+	if notice := frames[0].Meta.Notices[0].Text; notice != "Downsampled cpu from 999 to 100 points (lttb, max data points 100)" {
+		t.Errorf("unexpected notice %q", notice)
+	}
+}
+
+func TestDownsampleFrame_Unchanged(t *testing.T) {
+	frame := data.NewFrame("response",
+		data.NewField("time", nil, []time.Time{time.Unix(0, 0), time.Unix(1, 0)}),
+		data.NewField("value", nil, []float64{1, 2}),
+	)
+
+	for _, mode := range []string{"", downsampleMinMax} {
+		frames, err := DownsampleFrame(frame, 100, mode)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(frames) != 1 || frames[0] != frame {
+			t.Errorf("mode %q: expected frame to be returned unchanged", mode)
+		}
+	}
+}
+
+func TestDownsampleFrame_UnsupportedMode(t *testing.T) {
+	frame := data.NewFrame("response",
+		data.NewField("time", nil, []time.Time{time.Unix(0, 0), time.Unix(1, 0)}),
+		data.NewField("value", nil, []float64{1, 2}),
+	)
+
+	if _, err := DownsampleFrame(frame, 1, "median"); err == nil {
+		t.Fatal("expected error")
+	}
+}
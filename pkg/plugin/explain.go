@@ -0,0 +1,226 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const defaultLargeTableRows = 100000
+
+var (
+	// Matches "SCAN t", "SCAN t AS a" and the pre-3.36 form "SCAN TABLE t".
+	planScanRegex = regexp.MustCompile(`^SCAN (?:TABLE )?(\S+)`)
+	// Matches "AUTOMATIC INDEX ON t(col)" and "AUTOMATIC COVERING INDEX ON t(col)".
+	planAutoIndexRegex = regexp.MustCompile(`AUTOMATIC (?:COVERING |PARTIAL )*INDEX ON (\S+?)\(`)
+)
+
+// resourceQueryRequest is the request body of the /explain and /validate endpoints.
+type resourceQueryRequest struct {
+	Query      QueryModel `json:"query"`
+	From       int64      `json:"from"` // epoch milliseconds
+	To         int64      `json:"to"`   // epoch milliseconds
+	IntervalMS int64      `json:"intervalMs"`
+}
+
+// timeRange returns the request's time range, defaulting to the last hour.
+func (r resourceQueryRequest) timeRange() backend.TimeRange {
+	if r.From == 0 || r.To == 0 {
+		now := time.Now()
+		return backend.TimeRange{From: now.Add(-time.Hour), To: now}
+	}
+	return backend.TimeRange{From: time.UnixMilli(r.From), To: time.UnixMilli(r.To)}
+}
+
+// PlanNode is a single step of a SQLite query plan.
+type PlanNode struct {
+	ID       int         `json:"id"`
+	Parent   int         `json:"parent"`
+	Detail   string      `json:"detail"`
+	Children []*PlanNode `json:"children,omitempty"`
+}
+
+// PlanWarning flags a potentially expensive step of a query plan.
+type PlanWarning struct {
+	Severity string `json:"severity"` // "info" or "warning"
+	Message  string `json:"message"`
+	Table    string `json:"table,omitempty"`
+}
+
+// ExplainResponse is the response body of the /explain endpoint.
+type ExplainResponse struct {
+	SQL      string        `json:"sql"`
+	Plan     []*PlanNode   `json:"plan"`
+	Warnings []PlanWarning `json:"warnings"`
+}
+
+func (d *Datasource) handleExplain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req resourceQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Query.RawSQL == "" {
+		http.Error(w, "query is empty", http.StatusBadRequest)
+		return
+	}
+
+	sql, args, err := d.expandQuery(r.Context(), &req.Query, req.timeRange(), req.IntervalMS)
+	if errors.Is(err, errTableAccess) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := d.explain(r.Context(), sql, args)
+	if err != nil {
+		log.DefaultLogger.Error("Failed to explain query", "error", err)
+		http.Error(w, genericQueryErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// explain runs EXPLAIN QUERY PLAN for sql and analyzes the resulting plan.
+func (d *Datasource) explain(ctx context.Context, sql string, args []interface{}) (*ExplainResponse, error) {
+	result, err := d.queryResult(ctx, "EXPLAIN QUERY PLAN "+sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := buildPlanTree(result)
+	warnings, err := d.planWarnings(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExplainResponse{SQL: sql, Plan: plan, Warnings: warnings}, nil
+}
+
+// buildPlanTree converts the flat id/parent/detail rows of EXPLAIN QUERY PLAN
+// into a tree, returning the top-level nodes.
+func buildPlanTree(result *RqliteResult) []*PlanNode {
+	idIdx := slices.Index(result.Columns, "id")
+	parentIdx := slices.Index(result.Columns, "parent")
+	detailIdx := slices.Index(result.Columns, "detail")
+
+	nodes := make(map[int]*PlanNode, len(result.Values))
+	ordered := make([]*PlanNode, 0, len(result.Values))
+	for _, row := range result.Values {
+		node := &PlanNode{
+			ID:     int(toInt64(cellValue(row, idIdx))),
+			Parent: int(toInt64(cellValue(row, parentIdx))),
+		}
+		node.Detail, _ = cellValue(row, detailIdx).(string)
+		nodes[node.ID] = node
+		ordered = append(ordered, node)
+	}
+
+	roots := make([]*PlanNode, 0)
+	for _, node := range ordered {
+		if parent, ok := nodes[node.Parent]; ok && node.Parent != node.ID {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	return roots
+}
+
+// planWarnings flags full table scans, automatic indexes and temporary
+// B-trees. Full scans on tables with at least the configured number of rows
+// are reported as warnings, everything else as info.
+func (d *Datasource) planWarnings(ctx context.Context, plan []*PlanNode) ([]PlanWarning, error) {
+	tables, err := d.listTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := d.settings.LargeTableRows
+	if threshold <= 0 {
+		threshold = defaultLargeTableRows
+	}
+
+	warnings := make([]PlanWarning, 0)
+	var walk func(nodes []*PlanNode)
+	walk = func(nodes []*PlanNode) {
+		for _, node := range nodes {
+			switch {
+			case planScanRegex.MatchString(node.Detail) && !strings.Contains(node.Detail, " USING "):
+				table := planScanRegex.FindStringSubmatch(node.Detail)[1]
+				if !slices.Contains(tables, table) {
+					break
+				}
+				warnings = append(warnings, d.scanWarning(ctx, table, threshold))
+			case planAutoIndexRegex.MatchString(node.Detail):
+				table := planAutoIndexRegex.FindStringSubmatch(node.Detail)[1]
+				warnings = append(warnings, PlanWarning{
+					Severity: "info",
+					Message:  fmt.Sprintf("SQLite builds a temporary index on %q for this query, consider creating an index", table),
+					Table:    table,
+				})
+			case strings.HasPrefix(node.Detail, "USE TEMP B-TREE FOR "):
+				warnings = append(warnings, PlanWarning{
+					Severity: "info",
+					Message:  strings.TrimPrefix(node.Detail, "USE TEMP B-TREE FOR ") + " uses a temporary B-tree, consider an index on the sorted columns",
+				})
+			}
+			walk(node.Children)
+		}
+	}
+	walk(plan)
+
+	return warnings, nil
+}
+
+// scanWarning reports a full scan of table, estimating its size from the
+// largest rowid, which is cheap to look up even for very large tables.
+func (d *Datasource) scanWarning(ctx context.Context, table string, threshold int64) PlanWarning {
+	warning := PlanWarning{
+		Severity: "info",
+		Message:  fmt.Sprintf("Full table scan on %q without an index", table),
+		Table:    table,
+	}
+
+	result, err := d.queryResult(ctx, "SELECT MAX(rowid) FROM "+quoteIdentifier(table))
+	if err != nil || len(result.Values) == 0 {
+		// WITHOUT ROWID tables have no rowid to estimate the size from.
+		return warning
+	}
+
+	rows := toInt64(cellValue(result.Values[0], 0))
+	if rows >= threshold {
+		warning.Severity = "warning"
+		warning.Message = fmt.Sprintf("Full table scan on large table %q (about %d rows) without an index", table, rows)
+	}
+
+	return warning
+}
+
+// cellValue returns row[idx], or nil if idx is out of range.
+func cellValue(row []interface{}, idx int) interface{} {
+	if idx < 0 || idx >= len(row) {
+		return nil
+	}
+	return row[idx]
+}
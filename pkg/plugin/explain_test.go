@@ -0,0 +1,118 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBuildPlanTree(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"id", "parent", "notused", "detail"},
+		Values: [][]interface{}{
+			{float64(2), float64(0), float64(0), "SCAN metrics"},
+			{float64(5), float64(0), float64(0), "SCALAR SUBQUERY 1"},
+			{float64(9), float64(5), float64(0), "SEARCH hosts USING INDEX hosts_name (name=?)"},
+			{float64(20), float64(0), float64(0), "USE TEMP B-TREE FOR ORDER BY"},
+		},
+	}
+
+	plan := buildPlanTree(result)
+	if len(plan) != 3 {
+		t.Fatalf("expected 3 top-level nodes, got %d", len(plan))
+	}
+	if plan[1].Detail != "SCALAR SUBQUERY 1" || len(plan[1].Children) != 1 {
+		t.Fatalf("expected subquery with 1 child, got %+v", plan[1])
+	}
+	if plan[1].Children[0].Detail != "SEARCH hosts USING INDEX hosts_name (name=?)" {
+		t.Errorf("unexpected child: %+v", plan[1].Children[0])
+	}
+}
+
+func TestHandleExplain(t *testing.T) {
+	ds, rqliteServer := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		sql := decodeQueries(t, r)[0]
+		var result RqliteResult
+		switch {
+		case strings.HasPrefix(sql, "EXPLAIN QUERY PLAN "):
+			if sql != "EXPLAIN QUERY PLAN SELECT * FROM metrics m JOIN hosts h ON m.host = h.name WHERE time >= 1000 AND time <= 2000 ORDER BY value" {
+				t.Fatalf("unexpected explain query: %q", sql)
+			}
+			result = RqliteResult{
+				Columns: []string{"id", "parent", "notused", "detail"},
+				Values: [][]interface{}{
+					{float64(3), float64(0), float64(0), "SCAN m"},
+					{float64(4), float64(0), float64(0), "SCAN metrics AS m"},
+					{float64(6), float64(0), float64(0), "SCAN hosts AS h"},
+					{float64(7), float64(0), float64(0), "SEARCH h USING AUTOMATIC COVERING INDEX ON hosts(name)"},
+					{float64(20), float64(0), float64(0), "USE TEMP B-TREE FOR ORDER BY"},
+				},
+			}
+		case isSchemaQuery(sql):
+			result = schemaResult("hosts", "metrics")
+		case sql == `SELECT MAX(rowid) FROM "metrics"`:
+			result = RqliteResult{Columns: []string{"MAX(rowid)"}, Values: [][]interface{}{{float64(5_000_000)}}}
+		case sql == `SELECT MAX(rowid) FROM "hosts"`:
+			result = RqliteResult{Columns: []string{"MAX(rowid)"}, Values: [][]interface{}{{float64(12)}}}
+		default:
+			t.Fatalf("unexpected query: %q", sql)
+		}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer rqliteServer.Close()
+
+	body, _ := json.Marshal(resourceQueryRequest{
+		Query: QueryModel{RawSQL: "SELECT * FROM metrics m JOIN hosts h ON m.host = h.name WHERE $__timeFilter(time) ORDER BY value"},
+		From:  1000000,
+		To:    2000000,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/explain", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	ds.handleExplain(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp ExplainResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(resp.Plan) != 5 {
+		t.Fatalf("expected 5 plan nodes, got %d", len(resp.Plan))
+	}
+
+	expected := []PlanWarning{
+		{Severity: "warning", Table: "metrics"},
+		{Severity: "info", Table: "hosts"},
+		{Severity: "info", Table: "hosts"},
+		{Severity: "info"},
+	}
+	if len(resp.Warnings) != len(expected) {
+		t.Fatalf("expected %d warnings, got %+v", len(expected), resp.Warnings)
+	}
+	for i, w := range expected {
+		if resp.Warnings[i].Severity != w.Severity || resp.Warnings[i].Table != w.Table {
+			t.Errorf("warning %d: expected %s/%s, got %+v", i, w.Severity, w.Table, resp.Warnings[i])
+		}
+	}
+	if !strings.Contains(resp.Warnings[0].Message, "5000000 rows") {
+		t.Errorf("unexpected large table warning: %q", resp.Warnings[0].Message)
+	}
+}
+
+func TestHandleExplain_EmptyQuery(t *testing.T) {
+	ds := &Datasource{}
+
+	req := httptest.NewRequest(http.MethodPost, "/explain", strings.NewReader(`{"query":{"rawSql":""}}`))
+	rec := httptest.NewRecorder()
+	ds.handleExplain(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
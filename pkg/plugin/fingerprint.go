@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// placeholderListRegex matches parenthesized lists of placeholders such as
+// the values of an IN list, so that lists of any length normalize alike.
+var placeholderListRegex = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+
+// normalizeSQL replaces the string, blob and numeric literals of a statement
+// with ? placeholders, drops comments and collapses whitespace. Statements
+// that only differ in their literals, such as the time range of a time filter,
+// normalize to the same string, which holds no data from the literals.
+func normalizeSQL(sql string) string {
+	var b strings.Builder
+	space := false
+	emit := func(s string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			space = true
+			i += end
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i - 2
+			} else {
+				end += 2
+			}
+			space = true
+			i += end + 2
+		case c == '\'' || ((c == 'x' || c == 'X') && i+1 < len(sql) && sql[i+1] == '\''):
+			if c != '\'' {
+				i++
+			}
+			i = skipQuoted(sql, i, '\'')
+			emit("?")
+		case c == '"' || c == '`' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			start := i
+			i = skipQuoted(sql, i, end)
+			emit(sql[start:i])
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			i = skipNumber(sql, i)
+			emit("?")
+		case isIdentChar(c):
+			start := i
+			for i < len(sql) && isIdentChar(sql[i]) {
+				i++
+			}
+			emit(sql[start:i])
+		default:
+			emit(string(c))
+			i++
+		}
+	}
+
+	return placeholderListRegex.ReplaceAllString(b.String(), "(?)")
+}
+
+// sqlFingerprint returns a short hash of the normalized statement, to relate
+// executions of the same query without recording its literals.
+func sqlFingerprint(sql string) string {
+	sum := sha256.Sum256([]byte(normalizeSQL(sql)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// skipQuoted returns the index after the quoted token starting at i, where a
+// doubled closing quote is an escaped quote.
+func skipQuoted(sql string, i int, end byte) int {
+	for i++; i < len(sql); i++ {
+		if sql[i] != end {
+			continue
+		}
+		if end != ']' && i+1 < len(sql) && sql[i+1] == end {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(sql)
+}
+
+// skipNumber returns the index after the numeric literal starting at i,
+// including hexadecimal literals and exponents.
+func skipNumber(sql string, i int) int {
+	if strings.HasPrefix(sql[i:], "0x") || strings.HasPrefix(sql[i:], "0X") {
+		i += 2
+		for i < len(sql) && strings.IndexByte("0123456789abcdefABCDEF", sql[i]) >= 0 {
+			i++
+		}
+		return i
+	}
+	for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.') {
+		i++
+	}
+	if i < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+		i++
+		if i < len(sql) && (sql[i] == '+' || sql[i] == '-') {
+			i++
+		}
+		for i < len(sql) && isDigit(sql[i]) {
+			i++
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
@@ -0,0 +1,47 @@
+package plugin
+
+import "testing"
+
+func TestNormalizeSQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{
+			"SELECT time, value FROM metrics WHERE time >= 1700000000 AND time <= 1700003600",
+			"SELECT time, value FROM metrics WHERE time >= ? AND time <= ?",
+		},
+		{
+			"SELECT *\n  FROM logs -- recent\n WHERE host = 'it''s' AND level IN ('a', 'b', 'c')",
+			"SELECT * FROM logs WHERE host = ? AND level IN (?)",
+		},
+		{
+			`SELECT "col 1", x'CAFE', 1.5e3, 0xFF /* note */ FROM t2`,
+			`SELECT "col 1", ?, ?, ? FROM t2`,
+		},
+		{
+			"SELECT * FROM t WHERE id = ?",
+			"SELECT * FROM t WHERE id = ?",
+		},
+	}
+	for _, tt := range tests {
+		if got := normalizeSQL(tt.sql); got != tt.want {
+			t.Errorf("normalizeSQL(%q)\n got %q\nwant %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestSQLFingerprint(t *testing.T) {
+	a := sqlFingerprint("SELECT * FROM t WHERE time >= 1 AND host = 'a'")
+	b := sqlFingerprint("SELECT *  FROM t WHERE time >= 2 AND host = 'b'")
+	c := sqlFingerprint("SELECT * FROM u WHERE time >= 1 AND host = 'a'")
+	if a != b {
+		t.Errorf("expected statements differing in literals to match: %s != %s", a, b)
+	}
+	if a == c {
+		t.Error("expected statements on different tables to differ")
+	}
+	if len(a) != 16 {
+		t.Errorf("unexpected fingerprint %q", a)
+	}
+}
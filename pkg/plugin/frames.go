@@ -1,6 +1,7 @@
 package plugin
 
 import (
+	"encoding/base64"
 	"fmt"
 	"math"
 	"strings"
@@ -9,69 +10,196 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
-// ResultToFrame converts a rqlite result to a Grafana data frame.
-func ResultToFrame(result *RqliteResult, timeColumns []string) (*data.Frame, error) {
+// FrameOptions control how the columns of rqlite results are converted to
+// frame fields.
+type FrameOptions struct {
+	TimeColumns []TimeColumn
+
+	// BlobMode is how BLOB columns are rendered, see the blobMode constants,
+	// defaulting to base64. BlobColumns overrides it by column name.
+	BlobMode    string
+	BlobColumns map[string]string
+
+	// InferBooleans turns integer columns holding only 0 and 1 into boolean
+	// fields. Columns declared BOOLEAN or BOOL are boolean regardless.
+	InferBooleans bool
+
+	// DetectTimeColumns turns columns that hold times into time fields, see
+	// detectTimeFields. It only applies without TimeColumns.
+	DetectTimeColumns bool
+}
+
+// validate checks the time column settings and BLOB modes of the options.
+func (o FrameOptions) validate() error {
+	for _, tc := range o.TimeColumns {
+		if _, err := newTimeParser(tc); err != nil {
+			return fmt.Errorf("time column %q: %w", tc.Name, err)
+		}
+	}
+	if o.BlobMode != "" && !validBlobMode(o.BlobMode) {
+		return fmt.Errorf("unknown BLOB mode %q", o.BlobMode)
+	}
+	for col, mode := range o.BlobColumns {
+		if !validBlobMode(mode) {
+			return fmt.Errorf("unknown BLOB mode %q for column %q", mode, col)
+		}
+	}
+	return nil
+}
+
+// frameOptions returns the options for converting the query's results to
+// frames.
+func (qm QueryModel) frameOptions() FrameOptions {
+	return FrameOptions{
+		TimeColumns: qm.TimeColumns,
+		BlobMode:    qm.BlobMode,
+		BlobColumns: qm.BlobColumns,
+		// Rows streamed by live queries must keep the field types of the
+		// initial result, which inference could change from batch to batch.
+		InferBooleans:     qm.InferBooleans && !qm.Live,
+		DetectTimeColumns: qm.DetectTimeColumns,
+	}
+}
+
+// detectsTimeColumns reports whether time columns are detected rather than
+// listed.
+func (o FrameOptions) detectsTimeColumns() bool {
+	return o.DetectTimeColumns && len(o.TimeColumns) == 0
+}
+
+// blobMode returns the BLOB mode of a column.
+func (o FrameOptions) blobMode(col string) string {
+	for c, mode := range o.BlobColumns {
+		if strings.EqualFold(c, col) {
+			return mode
+		}
+	}
+	if o.BlobMode != "" {
+		return o.BlobMode
+	}
+	return blobModeBase64
+}
+
+// ResultToFrame converts a rqlite result to a Grafana data frame. If the
+// client already streamed the rows into a frame, that frame is returned.
+func ResultToFrame(result *RqliteResult, opts FrameOptions) (*data.Frame, error) {
 	if result.Error != "" {
 		return nil, fmt.Errorf("rqlite query error: %s", result.Error)
 	}
+	frame := result.Frame
+	if frame == nil {
+		b := newFrameBuilder(result.Columns, result.Types, opts, len(result.Values))
+		for _, row := range result.Values {
+			b.appendRow(row)
+		}
+		b.finish()
+		frame = b.frame
+	}
 
-	frame := data.NewFrame("response")
+	if opts.detectsTimeColumns() {
+		detectTimeFields(frame, result.Types)
+	}
+	if opts.InferBooleans {
+		inferBoolFields(frame)
+	}
+	return frame, nil
+}
+
+// frameBuilder appends rows of rqlite values to the typed fields of a frame.
+// timeParsers holds the parser of each time column and blobModes the BLOB
+// mode of each BLOB column; both are empty for other columns. timeErrors
+// counts the values of each time column that could not be parsed.
+type frameBuilder struct {
+	frame       *data.Frame
+	timeParsers []*timeParser
+	timeErrors  []int
+	blobModes   []string
+}
 
-	timeColSet := make(map[string]bool, len(timeColumns))
-	for _, tc := range timeColumns {
-		timeColSet[strings.ToLower(tc)] = true
+// newFrameBuilder returns a builder for the columns. The options must have
+// been validated.
+func newFrameBuilder(columns, types []string, opts FrameOptions, capacity int) *frameBuilder {
+	timeColSet := make(map[string]*timeParser, len(opts.TimeColumns))
+	for _, tc := range opts.TimeColumns {
+		p, err := newTimeParser(tc)
+		if err != nil {
+			p = defaultTimeParser
+		}
+		timeColSet[strings.ToLower(tc.Name)] = p
 	}
 
 	// Build fields based on column types
-	fields := make([]*data.Field, len(result.Columns))
-	for i, col := range result.Columns {
+	fields := make([]*data.Field, len(columns))
+	timeParsers := make([]*timeParser, len(columns))
+	blobModes := make([]string, len(columns))
+	for i, col := range columns {
 		colType := ""
-		if i < len(result.Types) {
-			colType = strings.ToLower(result.Types[i])
+		if i < len(types) {
+			colType = strings.ToLower(types[i])
 		}
 
-		if timeColSet[strings.ToLower(col)] {
-			fields[i] = data.NewField(col, nil, make([]*time.Time, 0, len(result.Values)))
-		} else {
-			fields[i] = newFieldForType(col, colType, len(result.Values))
+		timeParsers[i] = timeColSet[strings.ToLower(col)]
+		switch {
+		case timeParsers[i] != nil:
+			fields[i] = data.NewField(col, nil, make([]*time.Time, 0, capacity))
+		case strings.Contains(colType, "blob"):
+			blobModes[i] = opts.blobMode(col)
+			fields[i] = newBlobField(col, blobModes[i], capacity)
+		default:
+			fields[i] = newFieldForType(col, colType, capacity)
 		}
 	}
 
-	// Fill in values
-	for _, row := range result.Values {
-		for colIdx, field := range fields {
-			var val interface{}
-			if colIdx < len(row) {
-				val = row[colIdx]
+	frame := data.NewFrame("response")
+	frame.Fields = fields
+	return &frameBuilder{frame: frame, timeParsers: timeParsers, timeErrors: make([]int, len(columns)), blobModes: blobModes}
+}
+
+// appendRow appends a row, treating missing trailing values as NULL.
+func (b *frameBuilder) appendRow(row []interface{}) {
+	for colIdx, field := range b.frame.Fields {
+		var val interface{}
+		if colIdx < len(row) {
+			val = row[colIdx]
+		}
+		switch {
+		case b.timeParsers[colIdx] != nil:
+			if !appendTimeValue(field, val, b.timeParsers[colIdx]) {
+				b.timeErrors[colIdx]++
 			}
-			appendValue(field, val, timeColSet[strings.ToLower(result.Columns[colIdx])])
+		case b.blobModes[colIdx] != "":
+			appendBlobValue(field, val, b.blobModes[colIdx])
+		default:
+			appendValue(field, val)
 		}
 	}
+}
 
-	frame.Fields = fields
-	return frame, nil
+// finish adds a notice to the frame for each time column with values that
+// could not be parsed.
+func (b *frameBuilder) finish() {
+	for colIdx, n := range b.timeErrors {
+		if n > 0 {
+			appendTimeNotice(b.frame, b.frame.Fields[colIdx].Name, n)
+		}
+	}
 }
 
 func newFieldForType(name, colType string, capacity int) *data.Field {
 	switch {
+	case strings.Contains(colType, "bool"):
+		return data.NewField(name, nil, make([]*bool, 0, capacity))
 	case strings.Contains(colType, "int"):
 		return data.NewField(name, nil, make([]*int64, 0, capacity))
 	case strings.Contains(colType, "real") || strings.Contains(colType, "float") || strings.Contains(colType, "double") || strings.Contains(colType, "numeric"):
 		return data.NewField(name, nil, make([]*float64, 0, capacity))
-	case strings.Contains(colType, "blob"):
-		return data.NewField(name, nil, make([]*string, 0, capacity))
 	default:
 		// text, varchar, and anything else → string
 		return data.NewField(name, nil, make([]*string, 0, capacity))
 	}
 }
 
-func appendValue(field *data.Field, val interface{}, isTimeCol bool) {
-	if isTimeCol {
-		appendTimeValue(field, val)
-		return
-	}
-
+func appendValue(field *data.Field, val interface{}) {
 	if val == nil {
 		appendNilValue(field)
 		return
@@ -84,8 +212,14 @@ func appendValue(field *data.Field, val interface{}, isTimeCol bool) {
 	case data.FieldTypeNullableFloat64:
 		v := toFloat64(val)
 		field.Append(&v)
+	case data.FieldTypeNullableBool:
+		if v, ok := toBool(val); ok {
+			field.Append(&v)
+		} else {
+			field.Append((*bool)(nil))
+		}
 	case data.FieldTypeNullableString:
-		v := fmt.Sprintf("%v", val)
+		v := formatCell(val)
 		field.Append(&v)
 	default:
 		v := fmt.Sprintf("%v", val)
@@ -93,6 +227,18 @@ func appendValue(field *data.Field, val interface{}, isTimeCol bool) {
 	}
 }
 
+// formatCell formats a value for a string field. Byte arrays, as sent for
+// BLOB values of untyped columns with rqlite's blob_array option, are
+// rendered as base64 like BLOB columns.
+func formatCell(val interface{}) string {
+	if values, ok := val.([]interface{}); ok {
+		if b, ok := byteArray(values); ok {
+			return base64.StdEncoding.EncodeToString(b)
+		}
+	}
+	return fmt.Sprintf("%v", val)
+}
+
 func appendNilValue(field *data.Field) {
 	switch field.Type() {
 	case data.FieldTypeNullableInt64:
@@ -103,45 +249,28 @@ func appendNilValue(field *data.Field) {
 		field.Append((*string)(nil))
 	case data.FieldTypeNullableTime:
 		field.Append((*time.Time)(nil))
+	case data.FieldTypeNullableBool:
+		field.Append((*bool)(nil))
 	default:
 		field.Append((*string)(nil))
 	}
 }
 
-func appendTimeValue(field *data.Field, val interface{}) {
+// appendTimeValue appends a value of a time column. Values that cannot be
+// parsed are appended as null, reporting false.
+func appendTimeValue(field *data.Field, val interface{}, p *timeParser) bool {
 	if val == nil {
 		field.Append((*time.Time)(nil))
-		return
+		return true
 	}
 
-	t := parseTime(val)
-	field.Append(&t)
-}
-
-func parseTime(val interface{}) time.Time {
-	switch v := val.(type) {
-	case float64:
-		return unixToTime(v)
-	case string:
-		// Try RFC3339 first
-		if t, err := time.Parse(time.RFC3339, v); err == nil {
-			return t
-		}
-		// Try RFC3339Nano
-		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
-			return t
-		}
-		// Try common SQLite datetime format
-		if t, err := time.Parse("2006-01-02 15:04:05", v); err == nil {
-			return t
-		}
-		if t, err := time.Parse("2006-01-02", v); err == nil {
-			return t
-		}
-		return time.Time{}
-	default:
-		return time.Time{}
+	t, ok := p.parse(val)
+	if !ok {
+		field.Append((*time.Time)(nil))
+		return false
 	}
+	field.Append(&t)
+	return true
 }
 
 func unixToTime(v float64) time.Time {
@@ -179,6 +308,114 @@ func toInt64(val interface{}) int64 {
 	}
 }
 
+// toBool converts a value of a boolean column, reporting false for values
+// that are not booleans, such as strings other than true and false.
+func toBool(val interface{}) (bool, bool) {
+	switch v := val.(type) {
+	case bool:
+		return v, true
+	case float64:
+		return v != 0, true
+	case int64:
+		return v != 0, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "1":
+			return true, true
+		case "false", "0":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// minBoolSample is the number of non-null values from which integer columns
+// holding only 0 and 1 become booleans even if not named like flags, so that
+// counts and sums over a few rows do not.
+const minBoolSample = 10
+
+// boolColumnNames are column names and aliases that usually hold flags.
+var boolColumnNames = map[string]bool{
+	"up":      true,
+	"ok":      true,
+	"flag":    true,
+	"active":  true,
+	"enabled": true,
+	"online":  true,
+	"healthy": true,
+	"success": true,
+	"deleted": true,
+}
+
+var (
+	boolColumnPrefixes = []string{"is_", "has_", "can_"}
+	boolColumnSuffixes = []string{"_flag", "_enabled", "_active", "_ok"}
+)
+
+// inferBoolFields replaces the integer fields of a frame that hold only 0
+// and 1 with boolean fields. Fields named like flags need a single value,
+// other fields at least minBoolSample values with both 0 and 1.
+func inferBoolFields(frame *data.Frame) {
+	for i, field := range frame.Fields {
+		if field.Type() != data.FieldTypeNullableInt64 {
+			continue
+		}
+		zeros, ones, ok := countBinary(field)
+		if !ok || (!isBoolName(field.Name) && (zeros+ones < minBoolSample || zeros == 0 || ones == 0)) {
+			continue
+		}
+		bools := make([]*bool, field.Len())
+		for row := range bools {
+			if v, ok := field.ConcreteAt(row); ok {
+				b := v.(int64) == 1
+				bools[row] = &b
+			}
+		}
+		inferred := data.NewField(field.Name, field.Labels, bools)
+		inferred.Config = field.Config
+		frame.Fields[i] = inferred
+	}
+}
+
+// countBinary counts the 0 and 1 values of an integer field. It reports false
+// if the field has no values or other values.
+func countBinary(field *data.Field) (zeros, ones int, ok bool) {
+	for row := 0; row < field.Len(); row++ {
+		v, ok := field.ConcreteAt(row)
+		if !ok {
+			continue
+		}
+		switch v.(int64) {
+		case 0:
+			zeros++
+		case 1:
+			ones++
+		default:
+			return 0, 0, false
+		}
+	}
+	return zeros, ones, zeros+ones > 0
+}
+
+// isBoolName reports whether a column name or alias is usual for flags.
+func isBoolName(name string) bool {
+	name = strings.ToLower(name)
+	if boolColumnNames[name] {
+		return true
+	}
+	for _, prefix := range boolColumnPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for _, suffix := range boolColumnSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 func toFloat64(val interface{}) float64 {
 	switch v := val.(type) {
 	case float64:
@@ -191,3 +428,103 @@ func toFloat64(val interface{}) float64 {
 		return 0
 	}
 }
+
+// ToTimeSeriesFrame marks frame as a wide time series. Long frames, which have
+// string columns, are converted to wide frames whose string columns become
+// labels on the value fields. Their rows are sorted by time first, and rows
+// without a time are left out, as the conversion requires.
+func ToTimeSeriesFrame(frame *data.Frame) (*data.Frame, error) {
+	var notices []data.Notice
+	if frame.Meta != nil {
+		notices = frame.Meta.Notices
+	}
+	if frame.Rows() > 0 && frame.TimeSeriesSchema().Type == data.TimeSeriesTypeLong {
+		frame = withoutNullTimes(sortFrameByTime(frame))
+		if frame.Rows() > 0 {
+			wide, err := data.LongToWide(frame, nil)
+			if err != nil {
+				return nil, err
+			}
+			frame = wide
+		}
+	}
+
+	frame.Meta = &data.FrameMeta{
+		Type:    data.FrameTypeTimeSeriesWide,
+		Notices: notices,
+	}
+	return frame, nil
+}
+
+// withoutNullTimes drops the trailing rows of a frame sorted by time whose
+// time is null.
+func withoutNullTimes(frame *data.Frame) *data.Frame {
+	timeField := frame.Fields[frame.TimeSeriesSchema().TimeIndex]
+	n := frame.Rows()
+	for n > 0 {
+		if _, ok := timeField.ConcreteAt(n - 1); ok {
+			break
+		}
+		n--
+	}
+	if n == frame.Rows() {
+		return frame
+	}
+	return sliceFrame(frame, n)
+}
+
+// ToNumericMultiFrames converts a table frame to the numeric multi format used
+// by Grafana alerting. String columns become labels, and each numeric column
+// yields one single-value frame per row, so that every label set becomes a
+// separate alert instance.
+func ToNumericMultiFrames(frame *data.Frame) (data.Frames, error) {
+	var labelFields, valueFields []*data.Field
+	for _, field := range frame.Fields {
+		switch {
+		case field.Type().Numeric():
+			valueFields = append(valueFields, field)
+		case field.Type() == data.FieldTypeNullableString || field.Type() == data.FieldTypeString:
+			labelFields = append(labelFields, field)
+		}
+	}
+
+	if len(valueFields) == 0 {
+		return nil, fmt.Errorf("no numeric columns in result for alerting")
+	}
+
+	meta := func() *data.FrameMeta {
+		return &data.FrameMeta{
+			Type:        data.FrameTypeNumericMulti,
+			TypeVersion: data.FrameTypeVersion{0, 1},
+		}
+	}
+
+	rows, err := frame.RowLen()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return data.Frames{data.NewFrame(frame.Name).SetMeta(meta())}, nil
+	}
+
+	frames := make(data.Frames, 0, rows*len(valueFields))
+	for row := 0; row < rows; row++ {
+		labels := make(data.Labels, len(labelFields))
+		for _, field := range labelFields {
+			v, _ := field.ConcreteAt(row)
+			s, _ := v.(string)
+			labels[field.Name] = s
+		}
+
+		for _, field := range valueFields {
+			value := data.NewFieldFromFieldType(field.Type(), 1)
+			value.Name = field.Name
+			value.Labels = labels.Copy()
+			value.Set(0, field.CopyAt(row))
+
+			frames = append(frames, data.NewFrame(frame.Name, value).SetMeta(meta()))
+		}
+	}
+
+	return frames, nil
+}
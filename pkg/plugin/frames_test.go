@@ -3,6 +3,8 @@ package plugin
 import (
 	"testing"
 	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
 func TestResultToFrame_BasicTypes(t *testing.T) {
@@ -15,7 +17,7 @@ func TestResultToFrame_BasicTypes(t *testing.T) {
 		},
 	}
 
-	frame, err := ResultToFrame(result, nil)
+	frame, err := ResultToFrame(result, FrameOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -63,7 +65,7 @@ func TestResultToFrame_NullValues(t *testing.T) {
 		},
 	}
 
-	frame, err := ResultToFrame(result, nil)
+	frame, err := ResultToFrame(result, FrameOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -94,7 +96,7 @@ func TestResultToFrame_TimeColumns_Epoch(t *testing.T) {
 		},
 	}
 
-	frame, err := ResultToFrame(result, []string{"time"})
+	frame, err := ResultToFrame(result, FrameOptions{TimeColumns: []TimeColumn{{Name: "time"}}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -119,7 +121,7 @@ func TestResultToFrame_TimeColumns_RFC3339(t *testing.T) {
 		},
 	}
 
-	frame, err := ResultToFrame(result, []string{"ts"})
+	frame, err := ResultToFrame(result, FrameOptions{TimeColumns: []TimeColumn{{Name: "ts"}}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -143,7 +145,7 @@ func TestResultToFrame_TimeColumns_NullTime(t *testing.T) {
 		},
 	}
 
-	frame, err := ResultToFrame(result, []string{"time"})
+	frame, err := ResultToFrame(result, FrameOptions{TimeColumns: []TimeColumn{{Name: "time"}}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -159,7 +161,7 @@ func TestResultToFrame_Error(t *testing.T) {
 		Error: "some SQL error",
 	}
 
-	_, err := ResultToFrame(result, nil)
+	_, err := ResultToFrame(result, FrameOptions{})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -172,7 +174,7 @@ func TestResultToFrame_EmptyResult(t *testing.T) {
 		Values:  [][]interface{}{},
 	}
 
-	frame, err := ResultToFrame(result, nil)
+	frame, err := ResultToFrame(result, FrameOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -181,3 +183,228 @@ func TestResultToFrame_EmptyResult(t *testing.T) {
 		t.Errorf("expected 0 rows, got %d", frame.Fields[0].Len())
 	}
 }
+
+func TestToTimeSeriesFrame_LongToWide(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"time", "host", "cpu"},
+		Types:   []string{"integer", "text", "real"},
+		Values: [][]interface{}{
+			{float64(1700000000), "web-1", float64(10)},
+			{float64(1700000000), "web-2", float64(20)},
+			{float64(1700000060), "web-1", float64(11)},
+			{float64(1700000060), "web-2", float64(21)},
+		},
+	}
+
+	frame, err := ResultToFrame(result, FrameOptions{TimeColumns: []TimeColumn{{Name: "time"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wide, err := ToTimeSeriesFrame(frame)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if wide.Meta == nil || wide.Meta.Type != data.FrameTypeTimeSeriesWide {
+		t.Fatalf("expected time series wide meta, got %+v", wide.Meta)
+	}
+	if len(wide.Fields) != 3 {
+		t.Fatalf("expected time field and 2 value fields, got %d fields", len(wide.Fields))
+	}
+	if wide.Fields[0].Len() != 2 {
+		t.Errorf("expected 2 rows, got %d", wide.Fields[0].Len())
+	}
+	if wide.Fields[1].Labels["host"] != "web-1" || wide.Fields[2].Labels["host"] != "web-2" {
+		t.Errorf("unexpected labels: %v, %v", wide.Fields[1].Labels, wide.Fields[2].Labels)
+	}
+}
+
+func TestToTimeSeriesFrame_Unsorted(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"time", "host", "cpu"},
+		Types:   []string{"integer", "text", "real"},
+		Values: [][]interface{}{
+			{float64(1700000060), "web-1", float64(11)},
+			{nil, "web-1", float64(99)},
+			{float64(1700000000), "web-2", float64(20)},
+			{float64(1700000000), "web-1", float64(10)},
+		},
+	}
+
+	frame, err := ResultToFrame(result, FrameOptions{TimeColumns: []TimeColumn{{Name: "time"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wide, err := ToTimeSeriesFrame(frame)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wide.Fields[0].Len() != 2 {
+		t.Fatalf("expected 2 rows without the null time, got %d", wide.Fields[0].Len())
+	}
+	first, _ := wide.Fields[0].ConcreteAt(0)
+	if !first.(time.Time).Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("expected rows sorted by time, got %v first", first)
+	}
+}
+
+func TestToTimeSeriesFrame_Empty(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"time", "host", "cpu"},
+		Types:   []string{"integer", "text", "real"},
+	}
+
+	frame, err := ResultToFrame(result, FrameOptions{TimeColumns: []TimeColumn{{Name: "time"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := ToTimeSeriesFrame(frame); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestToNumericMultiFrames(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"host", "dc", "cpu", "mem"},
+		Types:   []string{"text", "text", "real", "integer"},
+		Values: [][]interface{}{
+			{"web-1", "fra", float64(10.5), float64(512)},
+			{"web-2", nil, float64(20.5), float64(1024)},
+		},
+	}
+
+	frame, err := ResultToFrame(result, FrameOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	frames, err := ToNumericMultiFrames(frame)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(frames) != 4 {
+		t.Fatalf("expected 4 frames, got %d", len(frames))
+	}
+
+	for _, f := range frames {
+		if f.Meta == nil || f.Meta.Type != data.FrameTypeNumericMulti {
+			t.Fatalf("expected numeric multi meta, got %+v", f.Meta)
+		}
+		if len(f.Fields) != 1 || f.Fields[0].Len() != 1 {
+			t.Fatalf("expected a single value field, got %d fields", len(f.Fields))
+		}
+	}
+
+	cpu := frames[2].Fields[0]
+	if cpu.Name != "cpu" {
+		t.Errorf("expected field cpu, got %q", cpu.Name)
+	}
+	if cpu.Labels["host"] != "web-2" || cpu.Labels["dc"] != "" {
+		t.Errorf("unexpected labels: %v", cpu.Labels)
+	}
+	if v := cpu.At(0).(*float64); *v != 20.5 {
+		t.Errorf("expected 20.5, got %v", *v)
+	}
+}
+
+func TestToNumericMultiFrames_NoNumericColumns(t *testing.T) {
+	frame, err := ResultToFrame(&RqliteResult{Columns: []string{"host"}, Types: []string{"text"}}, FrameOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := ToNumericMultiFrames(frame); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestResultToFrame_Booleans(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"enabled", "active", "flag"},
+		Types:   []string{"BOOLEAN", "bool", "text"},
+		Values: [][]interface{}{
+			{float64(1), "true", "x"},
+			{float64(0), "FALSE", "y"},
+			{nil, "maybe", nil},
+		},
+	}
+
+	frame, err := ResultToFrame(result, FrameOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := [][]interface{}{{true, false, nil}, {true, false, nil}}
+	for i, values := range want {
+		field := frame.Fields[i]
+		if field.Type() != data.FieldTypeNullableBool {
+			t.Fatalf("%s: expected a nullable bool field, got %v", field.Name, field.Type())
+		}
+		for row, v := range values {
+			got, ok := field.ConcreteAt(row)
+			if (v == nil && ok) || (v != nil && got != v) {
+				t.Errorf("%s row %d: got %v, want %v", field.Name, row, got, v)
+			}
+		}
+	}
+	if frame.Fields[2].Type() != data.FieldTypeNullableString {
+		t.Errorf("expected text columns to stay strings, got %v", frame.Fields[2].Type())
+	}
+}
+
+func TestResultToFrame_InferBooleans(t *testing.T) {
+	newResult := func() *RqliteResult {
+		return &RqliteResult{
+			Columns: []string{"up", "count", "empty", "total", "is_admin"},
+			Types:   []string{"integer", "integer", "integer", "integer", "integer"},
+			Values: [][]interface{}{
+				{float64(1), float64(1), nil, float64(1), float64(1)},
+				{nil, float64(2), nil, float64(0), nil},
+				{float64(0), float64(0), nil, float64(1), nil},
+			},
+		}
+	}
+
+	frame, err := ResultToFrame(newResult(), FrameOptions{InferBooleans: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// total holds only 0 and 1 but is not named like a flag, and too few
+	// values are sampled to tell it from a count.
+	wantTypes := []data.FieldType{
+		data.FieldTypeNullableBool,
+		data.FieldTypeNullableInt64,
+		data.FieldTypeNullableInt64,
+		data.FieldTypeNullableInt64,
+		data.FieldTypeNullableBool,
+	}
+	for i, want := range wantTypes {
+		if got := frame.Fields[i].Type(); got != want {
+			t.Errorf("%s: got %v, want %v", frame.Fields[i].Name, got, want)
+		}
+	}
+	if v, _ := frame.Fields[0].ConcreteAt(0); v != true {
+		t.Errorf("expected 1 to become true, got %v", v)
+	}
+	if _, ok := frame.Fields[0].ConcreteAt(1); ok {
+		t.Error("expected NULL to stay null")
+	}
+
+	frame, _ = ResultToFrame(newResult(), FrameOptions{})
+	if frame.Fields[0].Type() != data.FieldTypeNullableInt64 {
+		t.Error("expected no inference by default")
+	}
+
+	// With enough values holding both 0 and 1, any column is inferred.
+	result := &RqliteResult{Columns: []string{"total"}, Types: []string{"integer"}}
+	for i := 0; i < minBoolSample; i++ {
+		result.Values = append(result.Values, []interface{}{float64(i % 2)})
+	}
+	frame, _ = ResultToFrame(result, FrameOptions{InferBooleans: true})
+	if frame.Fields[0].Type() != data.FieldTypeNullableBool {
+		t.Errorf("expected a sampled 0/1 column to become bool, got %v", frame.Fields[0].Type())
+	}
+}
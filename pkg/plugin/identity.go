@@ -0,0 +1,118 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// identityMapKey is the secure JSON data key holding the identity map.
+const identityMapKey = "identityMap"
+
+// errNoIdentity is returned for Grafana users without mapped rqlite
+// credentials while identity forwarding is enabled.
+var errNoIdentity = errors.New("no rqlite credentials are mapped to this Grafana user")
+
+// Credentials are the basic auth credentials of an rqlite user.
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// IdentityMap maps Grafana users to rqlite credentials. A user's login is
+// looked up first, then their org role, then the default. Grafana does not
+// pass team memberships to plugins, so roles are the only groups available.
+type IdentityMap struct {
+	Users   map[string]Credentials `json:"users"`   // by Grafana login
+	Roles   map[string]Credentials `json:"roles"`   // by org role: Viewer, Editor or Admin
+	Default *Credentials           `json:"default"` // for anyone else, including alerting
+}
+
+// parseIdentityMap parses the identity map from secure JSON data.
+func parseIdentityMap(secure map[string]string) (*IdentityMap, error) {
+	raw := secure[identityMapKey]
+	if raw == "" {
+		return nil, errors.New("identity forwarding is enabled, but no identity map is set")
+	}
+	var m IdentityMap
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		return nil, fmt.Errorf("parsing identity map: %w", err)
+	}
+	return &m, nil
+}
+
+// resolve returns the credentials of a Grafana user, which is nil for
+// requests without a user, such as alert evaluations.
+func (m *IdentityMap) resolve(user *backend.User) (Credentials, error) {
+	if user != nil {
+		if creds, ok := m.Users[user.Login]; ok {
+			return creds, nil
+		}
+		if creds, ok := m.Roles[user.Role]; ok {
+			return creds, nil
+		}
+	}
+	if m.Default != nil {
+		return *m.Default, nil
+	}
+	return Credentials{}, errNoIdentity
+}
+
+// withIdentity returns a context whose requests to rqlite run as the rqlite
+// user mapped to the Grafana user. The context is returned unchanged if
+// identity forwarding is disabled.
+func (d *Datasource) withIdentity(ctx context.Context, user *backend.User) (context.Context, error) {
+	if d.identities == nil {
+		return ctx, nil
+	}
+	creds, err := d.identities.resolve(user)
+	if err != nil {
+		return nil, err
+	}
+	return withCredentials(ctx, creds), nil
+}
+
+// withUser scopes a context to the user of a request: queries run as their
+// rqlite user and only read the rows of their tenant.
+func (d *Datasource) withUser(ctx context.Context, pCtx backend.PluginContext) (context.Context, error) {
+	ctx, err := d.withIdentity(ctx, pCtx.User)
+	if err != nil {
+		return nil, err
+	}
+	return d.withTenant(ctx, pCtx)
+}
+
+// userScope identifies the rows the queries of a context can read, by rqlite
+// user and tenant. It is empty for the shared credentials without a tenant
+// policy.
+func userScope(ctx context.Context) string {
+	_, forwarded := credentialsFromContext(ctx)
+	if !forwarded && tenantFromContext(ctx) == nil {
+		return ""
+	}
+	return strconv.Quote(identityName(ctx)) + "/" + strconv.Quote(tenantName(ctx))
+}
+
+type credentialsKey struct{}
+
+// withCredentials returns a context whose requests to rqlite authenticate
+// with the credentials instead of the data source's shared ones.
+func withCredentials(ctx context.Context, creds Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, creds)
+}
+
+func credentialsFromContext(ctx context.Context) (Credentials, bool) {
+	creds, ok := ctx.Value(credentialsKey{}).(Credentials)
+	return creds, ok
+}
+
+// identityName returns the rqlite user of the context, or "" for the shared
+// credentials.
+func identityName(ctx context.Context) string {
+	creds, _ := credentialsFromContext(ctx)
+	return creds.Username
+}
@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+)
+
+func TestParseIdentityMap(t *testing.T) {
+	m, err := parseIdentityMap(map[string]string{
+		identityMapKey: `{"users":{"alice":{"username":"ro_alice","password":"a"}},"roles":{"Editor":{"username":"editors","password":"e"}},"default":{"username":"viewers","password":"v"}}`,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		user *backend.User
+		want string
+	}{
+		{"login", &backend.User{Login: "alice", Role: "Editor"}, "ro_alice"},
+		{"role", &backend.User{Login: "bob", Role: "Editor"}, "editors"},
+		{"default", &backend.User{Login: "carol", Role: "Viewer"}, "viewers"},
+		{"no user", nil, "viewers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := m.resolve(tt.user)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if creds.Username != tt.want {
+				t.Errorf("got %q, want %q", creds.Username, tt.want)
+			}
+		})
+	}
+
+	m.Default = nil
+	if _, err := m.resolve(&backend.User{Login: "carol"}); !errors.Is(err, errNoIdentity) {
+		t.Errorf("expected errNoIdentity without a default, got %v", err)
+	}
+
+	if _, err := parseIdentityMap(nil); err == nil {
+		t.Error("expected an error for a missing identity map")
+	}
+	if _, err := parseIdentityMap(map[string]string{identityMapKey: "{"}); err == nil {
+		t.Error("expected an error for an invalid identity map")
+	}
+}
+
+func TestIdentityForwarding(t *testing.T) {
+	var users []string
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		users = append(users, user)
+		result := RqliteResult{Columns: []string{"id"}, Types: []string{"integer"}, Values: [][]interface{}{{1.0}}}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+	ds.uid = "abc"
+	ds.liveQueries = make(map[string]*liveQuery)
+	ds.identities = &IdentityMap{Users: map[string]Credentials{
+		"alice": {Username: "ro_alice", Password: "secret"},
+		"bob":   {Username: "ro_bob", Password: "secret"},
+	}}
+
+	queryAs := func(login string, qm QueryModel) backend.DataResponse {
+		qmJSON, _ := json.Marshal(qm)
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			PluginContext: backend.PluginContext{User: &backend.User{Login: login}},
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: qmJSON}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return resp.Responses["A"]
+	}
+
+	if res := queryAs("alice", QueryModel{RawSQL: "SELECT id FROM t", Format: "table"}); res.Error != nil {
+		t.Fatalf("unexpected response error: %v", res.Error)
+	}
+	if len(users) != 1 || users[0] != "ro_alice" {
+		t.Errorf("expected the query to run as ro_alice, got %v", users)
+	}
+
+	res := queryAs("mallory", QueryModel{RawSQL: "SELECT id FROM t", Format: "table"})
+	if res.Status != backend.StatusForbidden {
+		t.Errorf("expected unmapped users to be forbidden, got status %v", res.Status)
+	}
+	if len(users) != 1 {
+		t.Errorf("expected no request for unmapped users, got %v", users)
+	}
+
+	live := QueryModel{RawSQL: "SELECT id FROM t", Format: "table", Live: true, LiveColumn: "id"}
+	aliceChannel := queryAs("alice", live).Frames[0].Meta.Channel
+	bobChannel := queryAs("bob", live).Frames[0].Meta.Channel
+	if aliceChannel == bobChannel {
+		t.Fatalf("expected users to get separate live channels, got %q", aliceChannel)
+	}
+	path := strings.TrimPrefix(aliceChannel, "ds/abc/")
+	for login, want := range map[string]backend.SubscribeStreamStatus{
+		"alice": backend.SubscribeStreamStatusOK,
+		"bob":   backend.SubscribeStreamStatusPermissionDenied,
+	} {
+		sub, err := ds.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{
+			PluginContext: backend.PluginContext{User: &backend.User{Login: login}},
+			Path:          path,
+		})
+		if err != nil || sub.Status != want {
+			t.Errorf("%s: expected subscribe status %v, got %v, %v", login, want, sub, err)
+		}
+	}
+}
+
+func TestIdentityForwarding_CallResource(t *testing.T) {
+	var users []string
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		users = append(users, user)
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{schemaResult("t")}})
+	})
+	defer server.Close()
+	ds.identities = &IdentityMap{Roles: map[string]Credentials{"Viewer": {Username: "viewers"}}}
+	mux := http.NewServeMux()
+	ds.registerRoutes(mux)
+	ds.resourceHandler = httpadapter.New(mux)
+
+	callAs := func(role string) int {
+		var status int
+		req := &backend.CallResourceRequest{
+			PluginContext: backend.PluginContext{User: &backend.User{Login: "someone", Role: role}},
+			Path:          "tables",
+			Method:        http.MethodGet,
+			URL:           "/tables",
+		}
+		err := ds.CallResource(context.Background(), req, backend.CallResourceResponseSenderFunc(func(resp *backend.CallResourceResponse) error {
+			status = resp.Status
+			return nil
+		}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return status
+	}
+
+	if status := callAs("Viewer"); status != http.StatusOK {
+		t.Errorf("expected status 200, got %d", status)
+	}
+	if len(users) != 1 || users[0] != "viewers" {
+		t.Errorf("expected the resource call to run as viewers, got %v", users)
+	}
+	if status := callAs("Admin"); status != http.StatusForbidden {
+		t.Errorf("expected status 403 for unmapped users, got %d", status)
+	}
+}
@@ -0,0 +1,302 @@
+package plugin
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+// defaultLiveInterval is how often live queries poll rqlite for new rows.
+const defaultLiveInterval = 5 * time.Second
+
+// liveQueryTTL is how long a registered live query is kept while no stream
+// runs it.
+const liveQueryTTL = 10 * time.Minute
+
+// liveQuery is a query registered by QueryData for streaming. After is the
+// high-water mark, the largest value of Column sent so far. Scope is the
+// user scope of the registering user, whose credentials and tenant the query
+// runs with.
+type liveQuery struct {
+	Query       QueryModel
+	From        time.Time
+	IntervalMS  int64
+	Column      string
+	After       interface{}
+	Scope       string
+	Credentials *Credentials
+	Tenant      *tenantScope
+
+	registered time.Time
+	streaming  bool
+}
+
+// liveColumn returns the high-water mark column of a live query, which
+// defaults to the first time column.
+func liveColumn(qm QueryModel) string {
+	if qm.LiveColumn != "" {
+		return qm.LiveColumn
+	}
+	if len(qm.TimeColumns) > 0 {
+		return qm.TimeColumns[0].Name
+	}
+	return ""
+}
+
+// livePath returns the channel path of a live query in a user scope.
+// Identical queries in the same scope share a path, and thereby a stream.
+func livePath(qm QueryModel, scope string) (string, error) {
+	b, err := json.Marshal(struct {
+		Query QueryModel
+		Scope string `json:",omitempty"`
+	}{qm, scope})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "live/" + hex.EncodeToString(sum[:16]), nil
+}
+
+// registerLive stores a live query with the high-water mark of its initial
+// result and returns the channel to subscribe to. Live queries without a
+// stream are removed after liveQueryTTL.
+func (d *Datasource) registerLive(ctx context.Context, qm QueryModel, query backend.DataQuery, result *RqliteResult) (string, error) {
+	column := liveColumn(qm)
+	if column == "" {
+		return "", errors.New("live queries need a live column")
+	}
+	colIdx := slices.IndexFunc(result.Columns, func(c string) bool { return strings.EqualFold(c, column) })
+	if colIdx < 0 {
+		return "", fmt.Errorf("live column %q is not in the query result", column)
+	}
+
+	path, err := livePath(qm, userScope(ctx))
+	if err != nil {
+		return "", err
+	}
+
+	var after interface{}
+	for _, row := range result.Values {
+		if v := cellValue(row, colIdx); v != nil && (after == nil || compareValues(v, after) > 0) {
+			after = v
+		}
+	}
+
+	lq := &liveQuery{
+		Query:      qm,
+		From:       query.TimeRange.From,
+		IntervalMS: query.Interval.Milliseconds(),
+		Column:     result.Columns[colIdx],
+		After:      after,
+		Scope:      userScope(ctx),
+		Tenant:     tenantFromContext(ctx),
+		registered: time.Now(),
+	}
+	if creds, ok := credentialsFromContext(ctx); ok {
+		lq.Credentials = &creds
+	}
+
+	d.liveMu.Lock()
+	for p, old := range d.liveQueries {
+		if !old.streaming && time.Since(old.registered) > liveQueryTTL {
+			delete(d.liveQueries, p)
+		}
+	}
+	if old, ok := d.liveQueries[path]; ok {
+		lq.streaming = old.streaming
+	}
+	d.liveQueries[path] = lq
+	d.liveMu.Unlock()
+
+	return live.Channel{Scope: live.ScopeDatasource, Namespace: d.uid, Path: path}.String(), nil
+}
+
+func (d *Datasource) liveQuery(path string) (liveQuery, bool) {
+	d.liveMu.Lock()
+	defer d.liveMu.Unlock()
+	lq, ok := d.liveQueries[path]
+	if !ok {
+		return liveQuery{}, false
+	}
+	return *lq, true
+}
+
+// startLive marks a live query as streaming and returns it with a function
+// to call when the stream ends. That function removes the query, unless it
+// was registered again in the meantime, in which case it is kept for a new
+// stream until liveQueryTTL.
+func (d *Datasource) startLive(path string) (liveQuery, func(), bool) {
+	d.liveMu.Lock()
+	defer d.liveMu.Unlock()
+	lq, ok := d.liveQueries[path]
+	if !ok {
+		return liveQuery{}, nil, false
+	}
+	lq.streaming = true
+	stop := func() {
+		d.liveMu.Lock()
+		defer d.liveMu.Unlock()
+		switch cur, ok := d.liveQueries[path]; {
+		case !ok:
+		case cur == lq:
+			delete(d.liveQueries, path)
+		default:
+			cur.streaming = false
+			cur.registered = time.Now()
+		}
+	}
+	return *lq, stop, true
+}
+
+// advanceLive moves the high-water mark of a live query forward.
+func (d *Datasource) advanceLive(path string, after interface{}) {
+	d.liveMu.Lock()
+	defer d.liveMu.Unlock()
+	if lq, ok := d.liveQueries[path]; ok && (lq.After == nil || compareValues(after, lq.After) > 0) {
+		lq.After = after
+	}
+}
+
+// SubscribeStream allows subscriptions to channels of registered live queries.
+// With identity forwarding or a tenant policy, only users in the user scope
+// of the query may subscribe.
+func (d *Datasource) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	lq, ok := d.liveQuery(req.Path)
+	if !ok {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+	if lq.Scope != "" {
+		ctx, err := d.withUser(ctx, req.PluginContext)
+		if err != nil || userScope(ctx) != lq.Scope {
+			return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusPermissionDenied}, nil
+		}
+	}
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+// PublishStream rejects publications, live channels are read-only.
+func (d *Datasource) PublishStream(_ context.Context, _ *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream polls rqlite for rows past the high-water mark of a live query
+// and sends them to the subscribers until the last one leaves. The live query
+// is removed when the stream ends.
+func (d *Datasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	lq, stop, ok := d.startLive(req.Path)
+	if !ok {
+		return fmt.Errorf("unknown live query %q", req.Path)
+	}
+	defer stop()
+
+	ctx = withQueryKind(ctx, queryKindLive)
+	if lq.Credentials != nil {
+		ctx = withCredentials(ctx, *lq.Credentials)
+	}
+	if lq.Tenant != nil {
+		ctx = withTenantScope(ctx, lq.Tenant)
+	}
+	ticker := time.NewTicker(d.liveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			frame, err := d.pollLive(ctx, req.Path)
+			if err != nil {
+				log.DefaultLogger.Error("Failed to poll live query", "error", err, "path", req.Path)
+				continue
+			}
+			if frame == nil {
+				continue
+			}
+			if err := sender.SendFrame(frame, data.IncludeAll); err != nil {
+				return fmt.Errorf("sending frame: %w", err)
+			}
+		}
+	}
+}
+
+// pollLive queries the rows of a live query past its high-water mark and
+// advances the mark. It returns nil if there are no new rows.
+func (d *Datasource) pollLive(ctx context.Context, path string) (*data.Frame, error) {
+	lq, ok := d.liveQuery(path)
+	if !ok {
+		return nil, fmt.Errorf("unknown live query %q", path)
+	}
+
+	timeRange := backend.TimeRange{From: lq.From, To: time.Now()}
+	sql, args, err := d.expandQuery(ctx, &lq.Query, timeRange, lq.IntervalMS)
+	if err != nil {
+		return nil, err
+	}
+	sql, args = buildLiveSQL(sql, lq.Column, lq.After, args)
+
+	resp, err := d.client.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 || len(resp.Results[0].Values) == 0 {
+		if len(resp.Results) > 0 && resp.Results[0].Error != "" {
+			return nil, fmt.Errorf("rqlite query error: %s", resp.Results[0].Error)
+		}
+		return nil, nil
+	}
+
+	result := &resp.Results[0]
+	colIdx := slices.Index(result.Columns, lq.Column)
+	if after := cellValue(result.Values[len(result.Values)-1], colIdx); after != nil {
+		d.advanceLive(path, after)
+	}
+
+	frame, err := ResultToFrame(result, lq.Query.frameOptions())
+	if err != nil {
+		return nil, err
+	}
+	if lq.Query.Format == "time_series" {
+		return ToTimeSeriesFrame(frame)
+	}
+	return frame, nil
+}
+
+// buildLiveSQL restricts a query to rows past the high-water mark, ordered by
+// the mark column so the last row holds the new mark.
+func buildLiveSQL(sql, column string, after interface{}, args []interface{}) (string, []interface{}) {
+	wrapped := subselect(sql)
+	col := quoteIdentifier(column)
+	if after == nil {
+		return fmt.Sprintf("%s WHERE %s IS NOT NULL ORDER BY %s", wrapped, col, col), args
+	}
+	return fmt.Sprintf("%s WHERE %s > ? ORDER BY %s", wrapped, col, col), append(args, after)
+}
+
+// compareValues compares two rqlite values the way SQLite orders them:
+// numbers before strings, numbers numerically and strings bytewise.
+func compareValues(a, b interface{}) int {
+	af, aNum := a.(float64)
+	bf, bNum := b.(float64)
+	switch {
+	case aNum && bNum:
+		return cmp.Compare(af, bf)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	default:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+}
@@ -0,0 +1,207 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestBuildLiveSQL(t *testing.T) {
+	sql, args := buildLiveSQL("SELECT rowid, v FROM t WHERE v > ?;", "rowid", nil, []interface{}{1.0})
+	if sql != `SELECT * FROM (SELECT rowid, v FROM t WHERE v > ?) WHERE "rowid" IS NOT NULL ORDER BY "rowid"` {
+		t.Errorf("unexpected SQL: %s", sql)
+	}
+	if !reflect.DeepEqual(args, []interface{}{1.0}) {
+		t.Errorf("unexpected args: %v", args)
+	}
+
+	sql, args = buildLiveSQL("SELECT rowid, v FROM t WHERE v > ?", "rowid", 7.0, []interface{}{1.0})
+	if sql != `SELECT * FROM (SELECT rowid, v FROM t WHERE v > ?) WHERE "rowid" > ? ORDER BY "rowid"` {
+		t.Errorf("unexpected SQL: %s", sql)
+	}
+	if !reflect.DeepEqual(args, []interface{}{1.0, 7.0}) {
+		t.Errorf("unexpected args: %v", args)
+	}
+
+	// Trailing comments would swallow the closing parenthesis.
+	for _, sql := range []string{
+		"SELECT rowid, v FROM t -- latest rows",
+		"SELECT rowid, v FROM t; -- latest rows\n",
+		"SELECT rowid, v FROM t /* latest */ ;",
+	} {
+		got, _ := buildLiveSQL(sql, "rowid", 7.0, nil)
+		if want := `SELECT * FROM (SELECT rowid, v FROM t) WHERE "rowid" > ? ORDER BY "rowid"`; got != want {
+			t.Errorf("%q: got %s", sql, got)
+		}
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		a, b interface{}
+		want int
+	}{
+		{2.0, 10.0, -1},
+		{10.0, 10.0, 0},
+		{"2024-01-02", "2024-01-01", 1},
+		{5.0, "a", -1},
+		{"a", 5.0, 1},
+	}
+	for _, tt := range tests {
+		if got := compareValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareValues(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDatasource_LiveQuery(t *testing.T) {
+	var statements [][]interface{}
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		var stmts []interface{}
+		_ = json.NewDecoder(r.Body).Decode(&stmts)
+		stmt, ok := stmts[0].([]interface{})
+		if !ok {
+			stmt = []interface{}{stmts[0]}
+		}
+		statements = append(statements, stmt)
+
+		result := RqliteResult{Columns: []string{"id", "value"}, Types: []string{"integer", "real"}}
+		switch len(statements) {
+		case 1:
+			result.Values = [][]interface{}{{float64(1), 1.5}, {float64(3), 2.5}, {float64(2), 3.5}}
+		case 2:
+			result.Values = [][]interface{}{{float64(4), 4.5}, {float64(5), 5.5}}
+		}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+	ds.uid = "abc"
+	ds.liveQueries = make(map[string]*liveQuery)
+
+	qm := QueryModel{RawSQL: "SELECT id, value FROM t", Format: "table", Live: true, LiveColumn: "id"}
+	qmJSON, _ := json.Marshal(qm)
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: qmJSON}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatalf("unexpected response error: %v", res.Error)
+	}
+	if rows := res.Frames[0].Rows(); rows != 3 {
+		t.Errorf("expected 3 initial rows, got %d", rows)
+	}
+	channel := res.Frames[0].Meta.Channel
+	if !strings.HasPrefix(channel, "ds/abc/live/") {
+		t.Fatalf("unexpected channel: %q", channel)
+	}
+	path := strings.TrimPrefix(channel, "ds/abc/")
+
+	sub, err := ds.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{Path: path})
+	if err != nil || sub.Status != backend.SubscribeStreamStatusOK {
+		t.Fatalf("unexpected subscribe result: %v, %v", sub, err)
+	}
+	sub, _ = ds.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{Path: "live/unknown"})
+	if sub.Status != backend.SubscribeStreamStatusNotFound {
+		t.Errorf("expected unknown path to be not found, got %v", sub.Status)
+	}
+
+	frame, err := ds.pollLive(context.Background(), path)
+	if err != nil {
+		t.Fatalf("unexpected poll error: %v", err)
+	}
+	if frame == nil || frame.Rows() != 2 {
+		t.Fatalf("expected 2 new rows, got %v", frame)
+	}
+	if got := statements[1][len(statements[1])-1]; got != float64(3) {
+		t.Errorf("expected the high-water mark 3 as last arg, got %v", got)
+	}
+
+	frame, err = ds.pollLive(context.Background(), path)
+	if err != nil || frame != nil {
+		t.Fatalf("expected no new rows, got %v, %v", frame, err)
+	}
+	if got := statements[2][len(statements[2])-1]; got != float64(5) {
+		t.Errorf("expected the high-water mark 5 as last arg, got %v", got)
+	}
+}
+
+func TestDatasource_LiveQueryMissingColumn(t *testing.T) {
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		result := RqliteResult{Columns: []string{"value"}, Types: []string{"real"}, Values: [][]interface{}{{1.5}}}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+	ds.liveQueries = make(map[string]*liveQuery)
+
+	qmJSON, _ := json.Marshal(QueryModel{RawSQL: "SELECT value FROM t", Live: true, TimeColumns: []TimeColumn{{Name: "time"}}})
+	resp, _ := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: qmJSON, TimeRange: backend.TimeRange{To: time.Now()}}},
+	})
+	res := resp.Responses["A"]
+	if res.Error == nil || !strings.Contains(res.Error.Error(), `live column "time"`) {
+		t.Errorf("expected live column error, got %v", res.Error)
+	}
+}
+
+func TestDatasource_LiveQueryCleanup(t *testing.T) {
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		result := RqliteResult{Columns: []string{"id"}, Types: []string{"integer"}, Values: [][]interface{}{{float64(1)}}}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+	ds.uid = "abc"
+	ds.liveInterval = time.Second
+	ds.liveQueries = make(map[string]*liveQuery)
+
+	register := func(sql string) string {
+		t.Helper()
+		qmJSON, _ := json.Marshal(QueryModel{RawSQL: sql, Live: true, LiveColumn: "id"})
+		resp, _ := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: qmJSON}},
+		})
+		res := resp.Responses["A"]
+		if res.Error != nil {
+			t.Fatalf("unexpected response error: %v", res.Error)
+		}
+		return strings.TrimPrefix(res.Frames[0].Meta.Channel, "ds/abc/")
+	}
+
+	// The live query is removed when its stream ends.
+	path := register("SELECT id FROM a")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ds.RunStream(ctx, &backend.RunStreamRequest{Path: path}, backend.NewStreamSender(nil)); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	if _, ok := ds.liveQuery(path); ok {
+		t.Error("expected the live query to be removed after its stream ended")
+	}
+
+	// Live queries that are never streamed expire.
+	stale := register("SELECT id FROM b")
+	ds.liveQueries[stale].registered = time.Now().Add(-2 * liveQueryTTL)
+	fresh := register("SELECT id FROM c")
+	if _, ok := ds.liveQuery(stale); ok {
+		t.Error("expected the unstreamed live query to expire")
+	}
+	if _, ok := ds.liveQuery(fresh); !ok {
+		t.Error("expected the new live query to be kept")
+	}
+
+	// Registering a streaming query again keeps it after the stream ends.
+	_, stop, _ := ds.startLive(fresh)
+	register("SELECT id FROM c")
+	stop()
+	if _, ok := ds.liveQuery(fresh); !ok {
+		t.Error("expected the registered again live query to be kept")
+	}
+}
@@ -16,12 +16,25 @@ var (
 	timeGroupRegex       = regexp.MustCompile(`\$__timeGroup\((\w+)\s*,\s*([^)]+)\)`)
 	timeFromRegex        = regexp.MustCompile(`\$__timeFrom`)
 	timeToRegex          = regexp.MustCompile(`\$__timeTo`)
+
+	macroRegex = regexp.MustCompile(`\$__(\w+)(\([^)]*\))?`)
 )
 
 // ApplyMacros replaces Grafana macros in a SQL string with SQLite-compatible expressions.
 func ApplyMacros(sql string, timeRange backend.TimeRange, intervalMS int64) string {
+	return applyMacros(sql, timeRange, intervalMS, false)
+}
+
+// applyMacros replaces Grafana macros like ApplyMacros. With openEnd, the time
+// filters exclude the end of the range, for chunks of split queries whose end
+// is the start of the next chunk.
+func applyMacros(sql string, timeRange backend.TimeRange, intervalMS int64, openEnd bool) string {
 	fromUnix := timeRange.From.Unix()
 	toUnix := timeRange.To.Unix()
+	toOp := "<="
+	if openEnd {
+		toOp = "<"
+	}
 
 	// $__timeFilter(col) → col >= <from> AND col <= <to>
 	sql = timeFilterRegex.ReplaceAllStringFunc(sql, func(match string) string {
@@ -30,7 +43,7 @@ func ApplyMacros(sql string, timeRange backend.TimeRange, intervalMS int64) stri
 			return match
 		}
 		col := sub[1]
-		return fmt.Sprintf("%s >= %d AND %s <= %d", col, fromUnix, col, toUnix)
+		return fmt.Sprintf("%s >= %d AND %s %s %d", col, fromUnix, col, toOp, toUnix)
 	})
 
 	// $__unixEpochFilter(col) → same as $__timeFilter
@@ -40,7 +53,7 @@ func ApplyMacros(sql string, timeRange backend.TimeRange, intervalMS int64) stri
 			return match
 		}
 		col := sub[1]
-		return fmt.Sprintf("%s >= %d AND %s <= %d", col, fromUnix, col, toUnix)
+		return fmt.Sprintf("%s >= %d AND %s %s %d", col, fromUnix, col, toOp, toUnix)
 	})
 
 	// $__timeGroup(col, interval) → (CAST(col / N AS INTEGER) * N)
@@ -95,3 +108,44 @@ func parseInterval(s string, intervalMS int64) int64 {
 
 	return 0
 }
+
+// ValidateMacros reports macros in sql that ApplyMacros would leave
+// unexpanded or expand with a fallback, such as unknown macro names, missing
+// column arguments or unparseable $__timeGroup intervals.
+func ValidateMacros(sql string) []SQLError {
+	var errs []SQLError
+
+	for _, loc := range macroRegex.FindAllStringSubmatchIndex(sql, -1) {
+		match := sql[loc[0]:loc[1]]
+		name := sql[loc[2]:loc[3]]
+
+		var msg string
+		switch name {
+		case "timeFilter", "unixEpochFilter":
+			if !timeFilterRegex.MatchString(match) && !unixEpochFilterRegex.MatchString(match) {
+				msg = fmt.Sprintf("$__%s expects a single column argument, e.g. $__%s(time)", name, name)
+			}
+		case "timeGroup":
+			sub := timeGroupRegex.FindStringSubmatch(match)
+			switch {
+			case sub == nil:
+				msg = "$__timeGroup expects a column and an interval argument, e.g. $__timeGroup(time, 5m)"
+			case strings.TrimSpace(sub[2]) != "$__interval" && parseInterval(sub[2], 0) <= 0:
+				msg = fmt.Sprintf("$__timeGroup has an invalid interval %q", strings.TrimSpace(sub[2]))
+			}
+		case "tenantFilter":
+			if !tenantFilterRegex.MatchString(match) {
+				msg = "$__tenantFilter expects a single column argument, e.g. $__tenantFilter(tenant)"
+			}
+		case "timeFrom", "timeTo", "adhocFilters", "interval":
+		default:
+			msg = fmt.Sprintf("unknown macro $__%s", name)
+		}
+
+		if msg != "" {
+			errs = append(errs, newSQLError(sql, loc[0], loc[1], "macro", msg))
+		}
+	}
+
+	return errs
+}
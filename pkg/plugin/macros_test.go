@@ -21,6 +21,20 @@ func TestApplyMacros_TimeFilter(t *testing.T) {
 	}
 }
 
+func TestApplyMacros_OpenEnd(t *testing.T) {
+	tr := backend.TimeRange{
+		From: time.Unix(1000, 0),
+		To:   time.Unix(2000, 0),
+	}
+
+	sql := "SELECT * FROM t WHERE $__timeFilter(ts) AND $__unixEpochFilter(ts) AND ts < $__timeTo"
+	result := applyMacros(sql, tr, 60000, true)
+	expected := "SELECT * FROM t WHERE ts >= 1000 AND ts < 2000 AND ts >= 1000 AND ts < 2000 AND ts < 2000"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
 func TestApplyMacros_UnixEpochFilter(t *testing.T) {
 	tr := backend.TimeRange{
 		From: time.Unix(500, 0),
@@ -146,3 +160,33 @@ func TestParseInterval(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateMacros(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected []string
+	}{
+		{"valid", "SELECT $__timeGroup(ts, $__interval), v FROM t WHERE $__timeFilter(ts) AND $__adhocFilters AND ts < $__timeTo", nil},
+		{"unknown macro", "SELECT * FROM t WHERE $__timeFiltr(ts)", []string{"unknown macro $__timeFiltr"}},
+		{"missing argument", "SELECT * FROM t WHERE $__timeFilter()", []string{"$__timeFilter expects a single column argument, e.g. $__timeFilter(time)"}},
+		{"bad interval", "SELECT $__timeGroup(ts, 5 minutes) FROM t", []string{`$__timeGroup has an invalid interval "5 minutes"`}},
+		{"tenant filter", "SELECT * FROM t WHERE $__tenantFilter(team)", nil},
+		{"tenant filter without column", "SELECT * FROM t WHERE $__tenantFilter()", []string{"$__tenantFilter expects a single column argument, e.g. $__tenantFilter(tenant)"}},
+		{"missing interval", "SELECT $__timeGroup(ts) FROM t", []string{"$__timeGroup expects a column and an interval argument, e.g. $__timeGroup(time, 5m)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateMacros(tt.sql)
+			if len(errs) != len(tt.expected) {
+				t.Fatalf("expected %d errors, got %+v", len(tt.expected), errs)
+			}
+			for i, msg := range tt.expected {
+				if errs[i].Message != msg || errs[i].Source != "macro" {
+					t.Errorf("expected %q, got %+v", msg, errs[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package plugin
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	metricsNamespace = "grafana_plugin"
+	metricsSubsystem = "rqlite"
+)
+
+// Query kinds used as metric label values.
+const (
+	queryKindTable      = "table"
+	queryKindTimeSeries = "time_series"
+	queryKindVariable   = "variable"
+	queryKindAlert      = "alert"
+	queryKindLive       = "live"
+	queryKindResource   = "resource"
+	queryKindUnknown    = "unknown"
+)
+
+// The collectors are registered with the default registry, which the plugin
+// SDK serves on its metrics endpoint.
+var (
+	queriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "queries_total",
+		Help:      "Number of data queries handled.",
+	}, []string{"datasource_uid", "query_kind"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "query_duration_seconds",
+		Help:      "Duration of data queries, from request to frames.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"datasource_uid", "query_kind"})
+
+	queryErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "query_errors_total",
+		Help:      "Number of failed data queries by error type.",
+	}, []string{"datasource_uid", "query_kind", "error_type"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests to rqlite, including reading the response.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"datasource_uid", "query_kind", "outcome"})
+
+	rowsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "rows_total",
+		Help:      "Number of rows returned by rqlite.",
+	}, []string{"datasource_uid", "query_kind"})
+
+	bytesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "response_bytes_total",
+		Help:      "Number of response bytes read from rqlite.",
+	}, []string{"datasource_uid", "query_kind"})
+
+	resourceCallsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "resource_calls_total",
+		Help:      "Number of resource calls by path and HTTP status.",
+	}, []string{"datasource_uid", "path", "status"})
+
+	chunkCacheTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "chunk_cache_requests_total",
+		Help:      "Number of chunk cache lookups of split queries by result, hit or miss.",
+	}, []string{"datasource_uid", "result"})
+)
+
+// Metrics records the activity of one datasource instance. A nil *Metrics
+// records nothing.
+type Metrics struct {
+	uid string
+}
+
+// NewMetrics returns the metrics of the datasource with the given UID.
+func NewMetrics(uid string) *Metrics {
+	return &Metrics{uid: uid}
+}
+
+// observeQuery records a data query and, if it failed, its error type.
+func (m *Metrics) observeQuery(kind string, start time.Time, res backend.DataResponse) {
+	if m == nil {
+		return
+	}
+	queriesTotal.WithLabelValues(m.uid, kind).Inc()
+	queryDuration.WithLabelValues(m.uid, kind).Observe(time.Since(start).Seconds())
+	if res.Error != nil {
+		queryErrorsTotal.WithLabelValues(m.uid, kind, errorType(res.Status)).Inc()
+	}
+}
+
+// observeRequest records a request to rqlite and the size of its response.
+func (m *Metrics) observeRequest(ctx context.Context, start time.Time, resp *RqliteQueryResponse, err error) {
+	if m == nil {
+		return
+	}
+	kind := queryKindFromContext(ctx)
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	requestDuration.WithLabelValues(m.uid, kind, outcome).Observe(time.Since(start).Seconds())
+	if resp == nil {
+		return
+	}
+	rows := 0
+	for _, result := range resp.Results {
+		rows += result.RowCount
+	}
+	rowsTotal.WithLabelValues(m.uid, kind).Add(float64(rows))
+	bytesTotal.WithLabelValues(m.uid, kind).Add(float64(resp.BytesReceived))
+}
+
+// observeResourceCall records a resource call with its response status.
+func (m *Metrics) observeResourceCall(path string, status int) {
+	if m == nil {
+		return
+	}
+	resourceCallsTotal.WithLabelValues(m.uid, resourcePathLabel(path), strconv.Itoa(status)).Inc()
+}
+
+// observeChunkCache records a chunk cache lookup.
+func (m *Metrics) observeChunkCache(hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	chunkCacheTotal.WithLabelValues(m.uid, result).Inc()
+}
+
+// queryKind classifies a data query for metric labels.
+func queryKind(req *backend.QueryDataRequest, query backend.DataQuery, qm QueryModel) string {
+	switch {
+	case query.QueryType == queryTypeVariable:
+		return queryKindVariable
+	case qm.Live:
+		return queryKindLive
+	case isAlertRequest(req):
+		return queryKindAlert
+	case qm.Format == "time_series":
+		return queryKindTimeSeries
+	default:
+		return queryKindTable
+	}
+}
+
+// errorType classifies a failed data query by its response status.
+func errorType(status backend.Status) string {
+	switch status {
+	case backend.StatusBadRequest:
+		return "bad_request"
+	case backend.StatusBadGateway:
+		return "unavailable"
+	case backend.StatusForbidden:
+		return "forbidden"
+	default:
+		return "internal"
+	}
+}
+
+// resourcePathLabel returns the resource path as a metric label value,
+// collapsing unknown paths to keep the label's cardinality bounded.
+func resourcePathLabel(path string) string {
+	path = strings.Trim(path, "/")
+	switch path {
+	case "tables", "columns", "values", "tag-keys", "tag-values", "explain", "validate":
+		return path
+	default:
+		return "other"
+	}
+}
+
+type queryKindKey struct{}
+
+// withQueryKind returns a context that labels requests to rqlite with the
+// query kind.
+func withQueryKind(ctx context.Context, kind string) context.Context {
+	return context.WithValue(ctx, queryKindKey{}, kind)
+}
+
+func queryKindFromContext(ctx context.Context) string {
+	if kind, ok := ctx.Value(queryKindKey{}).(string); ok {
+		return kind
+	}
+	return queryKindUnknown
+}
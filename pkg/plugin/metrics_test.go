@@ -0,0 +1,106 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_QueryData(t *testing.T) {
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		result := RqliteResult{Columns: []string{"v"}, Types: []string{"integer"}, Values: [][]interface{}{{1.0}, {2.0}}}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+	ds.metrics = NewMetrics("metrics-query")
+	ds.client.metrics = ds.metrics
+
+	good, _ := json.Marshal(QueryModel{RawSQL: "SELECT v FROM t", Format: "table"})
+	req := &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{RefID: "A", JSON: good},
+			{RefID: "B", JSON: []byte(`{"rawSql":""}`)},
+		},
+	}
+	if _, err := ds.QueryData(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := testutil.ToFloat64(queriesTotal.WithLabelValues("metrics-query", queryKindTable)); got != 2 {
+		t.Errorf("expected 2 queries, got %v", got)
+	}
+	if got := testutil.ToFloat64(queryErrorsTotal.WithLabelValues("metrics-query", queryKindTable, "bad_request")); got != 1 {
+		t.Errorf("expected 1 error, got %v", got)
+	}
+	if got := testutil.ToFloat64(rowsTotal.WithLabelValues("metrics-query", queryKindTable)); got != 2 {
+		t.Errorf("expected 2 rows, got %v", got)
+	}
+	if got := testutil.ToFloat64(bytesTotal.WithLabelValues("metrics-query", queryKindTable)); got == 0 {
+		t.Error("expected response bytes to be counted")
+	}
+	if got := testutil.CollectAndCount(requestDuration, "grafana_plugin_rqlite_request_duration_seconds"); got == 0 {
+		t.Error("expected request durations to be observed")
+	}
+}
+
+func TestMetrics_CallResource(t *testing.T) {
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{schemaResult("metrics")}})
+	})
+	defer server.Close()
+	ds.metrics = NewMetrics("metrics-resource")
+	mux := http.NewServeMux()
+	ds.registerRoutes(mux)
+	ds.resourceHandler = httpadapter.New(mux)
+
+	for _, path := range []string{"tables", "columns", "unknown"} {
+		req := &backend.CallResourceRequest{Path: path, Method: http.MethodGet, URL: "/" + path}
+		err := ds.CallResource(context.Background(), req, backend.CallResourceResponseSenderFunc(func(*backend.CallResourceResponse) error {
+			return nil
+		}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for _, tt := range []struct {
+		path, status string
+	}{
+		{"tables", "200"},
+		{"columns", "400"},
+		{"other", "404"},
+	} {
+		if got := testutil.ToFloat64(resourceCallsTotal.WithLabelValues("metrics-resource", tt.path, tt.status)); got != 1 {
+			t.Errorf("%s %s: expected 1 call, got %v", tt.path, tt.status, got)
+		}
+	}
+}
+
+func TestQueryKind(t *testing.T) {
+	alert := &backend.QueryDataRequest{Headers: map[string]string{"FromAlert": "true"}}
+	plain := &backend.QueryDataRequest{}
+
+	tests := []struct {
+		name  string
+		req   *backend.QueryDataRequest
+		query backend.DataQuery
+		qm    QueryModel
+		want  string
+	}{
+		{"table", plain, backend.DataQuery{}, QueryModel{Format: "table"}, queryKindTable},
+		{"time series", plain, backend.DataQuery{}, QueryModel{Format: "time_series"}, queryKindTimeSeries},
+		{"alert", alert, backend.DataQuery{}, QueryModel{Format: "time_series"}, queryKindAlert},
+		{"variable", plain, backend.DataQuery{QueryType: queryTypeVariable}, QueryModel{}, queryKindVariable},
+		{"live", plain, backend.DataQuery{}, QueryModel{Live: true}, queryKindLive},
+	}
+	for _, tt := range tests {
+		if got := queryKind(tt.req, tt.query, tt.qm); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,16 +1,91 @@
 package plugin
 
+import "github.com/grafana/grafana-plugin-sdk-go/data"
+
 // PluginSettings holds the datasource configuration.
 type PluginSettings struct {
 	ConsistencyLevel string `json:"consistencyLevel"`
 	Timeout          string `json:"timeout"`
+
+	// MaxRetries is how often queries are retried after transient failures,
+	// defaulting to 3. A negative value disables retries.
+	MaxRetries int `json:"maxRetries"`
+
+	// Circuit breaker settings. The breaker opens once BreakerErrorRate of
+	// recent queries failed, defaulting to 0.5, or a negative value to disable
+	// the breaker. Queries slower than the BreakerLatency duration count as
+	// failed. BreakerCooldown is how long the breaker stays open.
+	BreakerErrorRate float64 `json:"breakerErrorRate"`
+	BreakerLatency   string  `json:"breakerLatency"`
+	BreakerCooldown  string  `json:"breakerCooldown"`
+
+	// MaxConcurrentQueries bounds the queries in flight to rqlite, defaulting
+	// to 10. A negative value removes the limit.
+	MaxConcurrentQueries int `json:"maxConcurrentQueries"`
+
+	// AuditLog enables an audit record per data query. AuditSQL is "normalized"
+	// (default) to record the SQL with literals redacted, "raw" to record it as
+	// executed, or "none" to record only its fingerprint.
+	AuditLog bool   `json:"auditLog"`
+	AuditSQL string `json:"auditSql"`
+
+	// AllowedTables and DeniedTables are glob patterns of the tables the data
+	// source exposes, see tableAccess.
+	AllowedTables []string `json:"allowedTables"`
+	DeniedTables  []string `json:"deniedTables"`
+
+	// TenantPolicy restricts each user to the rows of their tenant, nil to
+	// disable tenant filtering.
+	TenantPolicy *TenantPolicy `json:"tenantPolicy"`
+
+	// IdentityForwarding runs queries as the rqlite user mapped to the Grafana
+	// user by the identity map in secure JSON data, see IdentityMap.
+	IdentityForwarding bool `json:"identityForwarding"`
+
+	// LiveInterval is how often live queries poll for new rows, as a Go
+	// duration string.
+	LiveInterval string `json:"liveInterval"`
+
+	// SplitConcurrency bounds how many chunks of a split query run at once.
+	// ChunkCacheSize is the number of historical chunks kept in memory, a
+	// negative size disables the cache.
+	SplitConcurrency int `json:"splitConcurrency"`
+	ChunkCacheSize   int `json:"chunkCacheSize"`
+
+	// LargeTableRows is the row count from which full table scans are reported
+	// as warnings by the /explain endpoint.
+	LargeTableRows int64 `json:"largeTableRows"`
+
+	// Result size limits, see ResultLimits. LimitAction is "truncate"
+	// (default) or "reject".
+	MaxRows          int64  `json:"maxRows"`
+	MaxResponseBytes int64  `json:"maxResponseBytes"`
+	LimitAction      string `json:"limitAction"`
 }
 
 // QueryModel represents a query from the frontend.
 type QueryModel struct {
-	RawSQL      string   `json:"rawSql"`
-	Format      string   `json:"format"` // "table" or "time_series"
-	TimeColumns []string `json:"timeColumns"`
+	RawSQL      string       `json:"rawSql"`
+	Format      string       `json:"format"`      // "table" or "time_series"
+	TimeColumns []TimeColumn `json:"timeColumns"` // names or objects with time formats
+	Downsample  string       `json:"downsample"`  // "", "lttb" or "minmax", applied to time series
+
+	// BlobMode is how BLOB columns are rendered: "base64" (default), "hex",
+	// "utf8", "length" or "datauri". BlobColumns overrides it by column.
+	BlobMode    string            `json:"blobMode"`
+	BlobColumns map[string]string `json:"blobColumns"`
+
+	// InferBooleans turns integer columns holding only 0 and 1 into
+	// booleans, for state timelines and value mappings.
+	InferBooleans bool `json:"inferBooleans"`
+
+	// DetectTimeColumns finds time columns by declared type, name and
+	// values when TimeColumns is empty.
+	DetectTimeColumns bool `json:"detectTimeColumns"`
+
+	// SplitDuration splits queries with a time filter into chunks of this
+	// length, such as "1d", run separately and merged.
+	SplitDuration string `json:"splitDuration"`
 
 	// Visual builder fields
 	EditorMode  string            `json:"editorMode"` // "code" or "builder"
@@ -20,6 +95,23 @@ type QueryModel struct {
 	GroupBy     []string          `json:"groupBy"`
 	OrderBy     []OrderByClause   `json:"orderBy"`
 	Limit       string            `json:"limit"`
+
+	// Variable query fields, used when the query type is "variable"
+	VariableRegex string `json:"variableRegex"`
+	VariableSort  string `json:"variableSort"` // "", "alphabetical-asc", "numerical-desc", ...
+
+	// Live queries stream rows past the high-water mark on LiveColumn, which
+	// defaults to the first time column.
+	Live       bool   `json:"live"`
+	LiveColumn string `json:"liveColumn"`
+
+	// AdhocFilters are the dashboard's ad hoc filters, added by the frontend.
+	AdhocFilters []AdhocFilter `json:"adhocFilters"`
+
+	// adhocResolved tells that AdhocFilters hold only the filters applicable
+	// to the query, so that its chunks and live polls do not look up its
+	// columns again.
+	adhocResolved bool
 }
 
 // ColumnSelection represents a column with an optional aggregation.
@@ -41,12 +133,34 @@ type OrderByClause struct {
 	Direction string `json:"direction"` // "ASC" or "DESC"
 }
 
+// AdhocFilter represents a single Grafana ad hoc filter.
+type AdhocFilter struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"` // "=", "!=", "<", ">", "<=", ">=", "=|", "!=|"
+	Value    string   `json:"value"`
+	Values   []string `json:"values"` // used by the multi-value operators "=|" and "!=|"
+}
+
 // RqliteQueryRequest is the request body sent to rqlite's /db/query endpoint.
-type RqliteQueryRequest []string
+// Each statement is either a SQL string or a parameterized [sql, args...] array.
+type RqliteQueryRequest []interface{}
 
 // RqliteQueryResponse is the response from rqlite's /db/query endpoint.
 type RqliteQueryResponse struct {
 	Results []RqliteResult `json:"results"`
+	Time    float64        `json:"time"` // total server time in seconds, set with ?timings
+
+	// BytesReceived is the size of the response body as read by the client.
+	BytesReceived int64 `json:"-"`
+	// Truncated describes the limit at which the response was truncated, if any.
+	Truncated string `json:"-"`
+	// Chunks is the number of chunks a split query ran as, CachedChunks how
+	// many of them were served from the chunk cache.
+	Chunks       int `json:"-"`
+	CachedChunks int `json:"-"`
+	// Retries is the number of times the query was retried after transient
+	// failures.
+	Retries int `json:"-"`
 }
 
 // RqliteResult is a single result set from rqlite.
@@ -55,4 +169,10 @@ type RqliteResult struct {
 	Types   []string        `json:"types"`
 	Values  [][]interface{} `json:"values"`
 	Error   string          `json:"error"`
+	Time    float64         `json:"time"` // statement time in seconds, set with ?timings
+
+	// Frame holds the rows when they were decoded straight into a frame,
+	// in which case Values is empty.
+	Frame    *data.Frame `json:"-"`
+	RowCount int         `json:"-"` // rows decoded, whether in Values or Frame
 }
@@ -0,0 +1,16 @@
+package plugin
+
+import "strings"
+
+// quoteIdentifier quotes a SQLite identifier by wrapping it in double quotes
+// and doubling any embedded double quotes, as described in
+// https://www.sqlite.org/lang_keywords.html.
+func quoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes a SQLite string literal by wrapping it in single quotes
+// and doubling any embedded single quotes.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,27 @@
+package plugin
+
+import "testing"
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name       string
+		identifier string
+		expected   string
+	}{
+		{"plain", "users", `"users"`},
+		{"hyphen", "http-requests", `"http-requests"`},
+		{"space", "order items", `"order items"`},
+		{"non-ASCII", "Messwerte_ä", `"Messwerte_ä"`},
+		{"embedded quote", `weird"name`, `"weird""name"`},
+		{"backslash", `a\b`, `"a\b"`},
+		{"empty", "", `""`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteIdentifier(tt.identifier); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -1,15 +1,23 @@
 package plugin
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"net/http"
-	"regexp"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 )
 
-var tableNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+var (
+	errUnknownTable  = errors.New("unknown table")
+	errUnknownColumn = errors.New("unknown column")
+)
 
 // ColumnInfo represents column metadata returned by the /columns endpoint.
 type ColumnInfo struct {
@@ -20,77 +28,224 @@ type ColumnInfo struct {
 func (d *Datasource) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/tables", d.handleTables)
 	mux.HandleFunc("/columns", d.handleColumns)
+	mux.HandleFunc("/values", d.handleValues)
+	mux.HandleFunc("/tag-keys", d.handleTagKeys)
+	mux.HandleFunc("/tag-values", d.handleTagValues)
+	mux.HandleFunc("/explain", d.handleExplain)
+	mux.HandleFunc("/validate", d.handleValidate)
 }
 
 func (d *Datasource) handleTables(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	tables, err := d.listTables(r.Context())
+	if err != nil {
+		log.DefaultLogger.Error("Failed to load tables from rqlite", "error", err)
+		http.Error(w, genericQueryErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tables)
+}
+
+func (d *Datasource) handleColumns(w http.ResponseWriter, r *http.Request) {
+	table := r.URL.Query().Get("table")
+	if table == "" {
+		http.Error(w, "table parameter is required", http.StatusBadRequest)
+		return
+	}
 
-	resp, err := d.client.Query(ctx, "SELECT name FROM sqlite_master WHERE type='table' ORDER BY name")
+	columns, err := d.tableColumns(r.Context(), table)
+	if errors.Is(err, errTableAccess) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, errUnknownTable) {
+		http.Error(w, "invalid table parameter", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		log.DefaultLogger.Error("Failed to query tables", "error", err)
+		log.DefaultLogger.Error("Failed to load columns from rqlite", "error", err, "table", table)
 		http.Error(w, genericQueryErrorMessage, http.StatusInternalServerError)
 		return
 	}
 
-	if len(resp.Results) == 0 || resp.Results[0].Error != "" {
-		errMsg := "no results"
-		if len(resp.Results) > 0 {
-			errMsg = resp.Results[0].Error
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(columns)
+}
+
+func (d *Datasource) handleValues(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	q := valuesQuery{
+		Table:      params.Get("table"),
+		Column:     params.Get("column"),
+		Prefix:     params.Get("prefix"),
+		TimeColumn: params.Get("timeColumn"),
+	}
+	if q.Table == "" || q.Column == "" {
+		http.Error(w, "table and column parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit parameter", http.StatusBadRequest)
+			return
 		}
-		log.DefaultLogger.Error("Failed to load tables from rqlite", "error", errMsg)
+		q.Limit = n
+	}
+
+	// from and to are epoch milliseconds, as used by Grafana time ranges.
+	if from, to := params.Get("from"), params.Get("to"); from != "" && to != "" {
+		fromMS, fromErr := strconv.ParseInt(from, 10, 64)
+		toMS, toErr := strconv.ParseInt(to, 10, 64)
+		if fromErr != nil || toErr != nil {
+			http.Error(w, "invalid time range parameters", http.StatusBadRequest)
+			return
+		}
+		q.From = time.UnixMilli(fromMS)
+		q.To = time.UnixMilli(toMS)
+	}
+
+	values, err := d.distinctValues(r.Context(), q)
+	switch {
+	case errors.Is(err, errTableAccess):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, errUnknownTable):
+		http.Error(w, "invalid table parameter", http.StatusBadRequest)
+		return
+	case errors.Is(err, errUnknownColumn):
+		http.Error(w, "invalid column parameter", http.StatusBadRequest)
+		return
+	case err != nil:
+		log.DefaultLogger.Error("Failed to load column values from rqlite", "error", err, "table", q.Table, "column", q.Column)
 		http.Error(w, genericQueryErrorMessage, http.StatusInternalServerError)
 		return
 	}
 
-	tables := make([]string, 0, len(resp.Results[0].Values))
-	for _, row := range resp.Results[0].Values {
-		if len(row) > 0 {
-			if name, ok := row[0].(string); ok {
-				tables = append(tables, name)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(values)
+}
+
+func (d *Datasource) handleTagKeys(w http.ResponseWriter, r *http.Request) {
+	schema, err := d.schemaColumns(r.Context())
+	if err != nil {
+		log.DefaultLogger.Error("Failed to load ad hoc filter keys from rqlite", "error", err)
+		http.Error(w, genericQueryErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	for _, columns := range schema {
+		for _, col := range columns {
+			if !seen[col.Name] {
+				seen[col.Name] = true
+				keys = append(keys, col.Name)
 			}
 		}
 	}
+	slices.Sort(keys)
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(tables)
+	_ = json.NewEncoder(w).Encode(keys)
 }
 
-func (d *Datasource) handleColumns(w http.ResponseWriter, r *http.Request) {
+func (d *Datasource) handleTagValues(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	table := r.URL.Query().Get("table")
-	if table == "" {
-		http.Error(w, "table parameter is required", http.StatusBadRequest)
-		return
-	}
-	if !isSafeTableName(table) {
-		http.Error(w, "invalid table parameter", http.StatusBadRequest)
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "key parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	// Use PRAGMA table_info to get column information.
-	// PRAGMA returns: cid, name, type, notnull, dflt_value, pk
-	resp, err := d.client.Query(ctx, "PRAGMA table_info("+quoteIdentifier(table)+")")
+	schema, err := d.schemaColumns(ctx)
 	if err != nil {
-		log.DefaultLogger.Error("Failed to query columns", "error", err)
+		log.DefaultLogger.Error("Failed to load ad hoc filter values from rqlite", "error", err, "key", key)
 		http.Error(w, genericQueryErrorMessage, http.StatusInternalServerError)
 		return
 	}
 
-	if len(resp.Results) == 0 || resp.Results[0].Error != "" {
-		errMsg := "no results"
-		if len(resp.Results) > 0 {
-			errMsg = resp.Results[0].Error
+	// Collect the distinct values of the key across all tables that have it.
+	seen := make(map[string]bool)
+	values := make([]string, 0)
+	for _, table := range slices.Sorted(maps.Keys(schema)) {
+		if !hasColumn(schema[table], key) {
+			continue
 		}
-		log.DefaultLogger.Error("Failed to load columns from rqlite", "error", errMsg, "table", table)
-		http.Error(w, genericQueryErrorMessage, http.StatusInternalServerError)
-		return
+
+		tableValues, err := d.queryValues(ctx, valuesQuery{Table: table, Column: key})
+		if err != nil {
+			log.DefaultLogger.Error("Failed to load ad hoc filter values from rqlite", "error", err, "key", key, "table", table)
+			http.Error(w, genericQueryErrorMessage, http.StatusInternalServerError)
+			return
+		}
+
+		for _, v := range tableValues {
+			if !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+	slices.Sort(values)
+	if len(values) > maxValuesLimit {
+		values = values[:maxValuesLimit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(values)
+}
+
+// listTables returns the names of all tables in the rqlite schema that the
+// data source exposes.
+func (d *Datasource) listTables(ctx context.Context) ([]string, error) {
+	result, err := d.queryResult(ctx, "SELECT name FROM sqlite_master WHERE type='table' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]string, 0, len(result.Values))
+	for _, row := range result.Values {
+		if len(row) > 0 {
+			if name, ok := row[0].(string); ok && d.tables.allows(name) {
+				tables = append(tables, name)
+			}
+		}
+	}
+
+	return tables, nil
+}
+
+// tableColumns validates table against the schema and returns its columns.
+// It returns errUnknownTable if the table does not exist and an error
+// wrapping errTableAccess if the data source does not expose it.
+func (d *Datasource) tableColumns(ctx context.Context, table string) ([]ColumnInfo, error) {
+	if !d.tables.allows(table) {
+		return nil, tableAccessError(table)
+	}
+	tables, err := d.listTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(tables, table) {
+		return nil, errUnknownTable
+	}
+
+	// Use PRAGMA table_info to get column information.
+	// PRAGMA returns: cid, name, type, notnull, dflt_value, pk
+	result, err := d.queryResult(ctx, "PRAGMA table_info("+quoteIdentifier(table)+")")
+	if err != nil {
+		return nil, err
 	}
 
 	// Find column indexes for name and type
 	nameIdx := -1
 	typeIdx := -1
-	for i, col := range resp.Results[0].Columns {
+	for i, col := range result.Columns {
 		switch col {
 		case "name":
 			nameIdx = i
@@ -100,12 +255,11 @@ func (d *Datasource) handleColumns(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if nameIdx < 0 {
-		http.Error(w, "unexpected PRAGMA result format", http.StatusInternalServerError)
-		return
+		return nil, errors.New("unexpected PRAGMA result format")
 	}
 
-	columns := make([]ColumnInfo, 0, len(resp.Results[0].Values))
-	for _, row := range resp.Results[0].Values {
+	columns := make([]ColumnInfo, 0, len(result.Values))
+	for _, row := range result.Values {
 		col := ColumnInfo{}
 		if nameIdx < len(row) {
 			if name, ok := row[nameIdx].(string); ok {
@@ -120,14 +274,49 @@ func (d *Datasource) handleColumns(w http.ResponseWriter, r *http.Request) {
 		columns = append(columns, col)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(columns)
+	return columns, nil
 }
 
-func isSafeTableName(table string) bool {
-	return tableNamePattern.MatchString(table)
+// schemaColumns returns the columns of every table in the rqlite schema that
+// the data source exposes, keyed by table name, using a single query.
+func (d *Datasource) schemaColumns(ctx context.Context) (map[string][]ColumnInfo, error) {
+	result, err := d.queryResult(ctx, "SELECT m.name, p.name, p.type FROM sqlite_master AS m JOIN pragma_table_info(m.name) AS p WHERE m.type='table' ORDER BY m.name, p.cid")
+	if err != nil {
+		return nil, err
+	}
+
+	schema := make(map[string][]ColumnInfo)
+	for _, row := range result.Values {
+		if len(row) < 3 {
+			continue
+		}
+		table, ok := row[0].(string)
+		if !ok || !d.tables.allows(table) {
+			continue
+		}
+		col := ColumnInfo{}
+		col.Name, _ = row[1].(string)
+		col.Type, _ = row[2].(string)
+		schema[table] = append(schema[table], col)
+	}
+
+	return schema, nil
 }
 
-func quoteIdentifier(identifier string) string {
-	return strconv.Quote(identifier)
+// queryResult runs sql and returns its first result set, treating a missing
+// result or a statement error as a failure.
+func (d *Datasource) queryResult(ctx context.Context, sql string, args ...interface{}) (*RqliteResult, error) {
+	resp, err := d.client.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Results) == 0 {
+		return nil, errors.New("no results")
+	}
+	if resp.Results[0].Error != "" {
+		return nil, fmt.Errorf("rqlite query error: %s", resp.Results[0].Error)
+	}
+
+	return &resp.Results[0], nil
 }
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -27,6 +29,36 @@ func setupTestDatasource(t *testing.T, rqliteHandler http.HandlerFunc) (*Datasou
 	return ds, rqliteServer
 }
 
+// schemaResult returns the sqlite_master listing for the given tables.
+func schemaResult(tables ...string) RqliteResult {
+	values := make([][]interface{}, 0, len(tables))
+	for _, table := range tables {
+		values = append(values, []interface{}{table})
+	}
+	return RqliteResult{
+		Columns: []string{"name"},
+		Types:   []string{"text"},
+		Values:  values,
+	}
+}
+
+func decodeQueries(t *testing.T, r *http.Request) []string {
+	t.Helper()
+
+	var queries []string
+	if err := json.NewDecoder(r.Body).Decode(&queries); err != nil {
+		t.Fatalf("failed to decode request body: %v", err)
+	}
+	if len(queries) != 1 {
+		t.Fatalf("expected 1 query, got %d", len(queries))
+	}
+	return queries
+}
+
+func isSchemaQuery(sql string) bool {
+	return strings.HasPrefix(sql, "SELECT name FROM sqlite_master")
+}
+
 func TestHandleTables(t *testing.T) {
 	ds, rqliteServer := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
 		resp := RqliteQueryResponse{
@@ -72,6 +104,10 @@ func TestHandleColumns(t *testing.T) {
 		if got := r.URL.Query().Get("q"); got != "" {
 			t.Fatalf("unexpected query string %q", got)
 		}
+		if isSchemaQuery(decodeQueries(t, r)[0]) {
+			_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{schemaResult("users")}})
+			return
+		}
 
 		resp := RqliteQueryResponse{
 			Results: []RqliteResult{
@@ -123,8 +159,15 @@ func TestHandleColumns_MissingTable(t *testing.T) {
 	}
 }
 
-func TestHandleColumns_RejectsUnsafeTableName(t *testing.T) {
-	ds := &Datasource{}
+func TestHandleColumns_RejectsUnknownTable(t *testing.T) {
+	ds, rqliteServer := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		queries := decodeQueries(t, r)
+		if !isSchemaQuery(queries[0]) {
+			t.Fatalf("unexpected query for unknown table: %q", queries[0])
+		}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{schemaResult("users")}})
+	})
+	defer rqliteServer.Close()
 
 	req := httptest.NewRequest(http.MethodGet, "/columns?table=users%3BDROP%20TABLE%20users", nil)
 	rec := httptest.NewRecorder()
@@ -140,37 +183,50 @@ func TestHandleColumns_RejectsUnsafeTableName(t *testing.T) {
 }
 
 func TestHandleColumns_QuotesValidatedTableName(t *testing.T) {
-	ds, rqliteServer := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
-		var queries []string
-		if err := json.NewDecoder(r.Body).Decode(&queries); err != nil {
-			t.Fatalf("failed to decode request body: %v", err)
-		}
-		if len(queries) != 1 {
-			t.Fatalf("expected 1 query, got %d", len(queries))
-		}
-		if queries[0] != `PRAGMA table_info("users")` {
-			t.Fatalf("unexpected query: %q", queries[0])
-		}
+	tests := []struct {
+		table    string
+		expected string
+	}{
+		{"users", `PRAGMA table_info("users")`},
+		{"http-requests", `PRAGMA table_info("http-requests")`},
+		{"order items", `PRAGMA table_info("order items")`},
+		{"Zählerstände", `PRAGMA table_info("Zählerstände")`},
+		{`odd"name`, `PRAGMA table_info("odd""name")`},
+	}
 
-		resp := RqliteQueryResponse{
-			Results: []RqliteResult{
-				{
-					Columns: []string{"cid", "name", "type"},
-					Types:   []string{"integer", "text", "text"},
-					Values:  [][]interface{}{{float64(0), "id", "INTEGER"}},
-				},
-			},
-		}
-		_ = json.NewEncoder(w).Encode(resp)
-	})
-	defer rqliteServer.Close()
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			ds, rqliteServer := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+				queries := decodeQueries(t, r)
+				if isSchemaQuery(queries[0]) {
+					_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{schemaResult(tt.table)}})
+					return
+				}
+				if queries[0] != tt.expected {
+					t.Fatalf("unexpected query: %q", queries[0])
+				}
 
-	req := httptest.NewRequest(http.MethodGet, "/columns?table=users", nil)
-	rec := httptest.NewRecorder()
-	ds.handleColumns(rec, req)
+				resp := RqliteQueryResponse{
+					Results: []RqliteResult{
+						{
+							Columns: []string{"cid", "name", "type"},
+							Types:   []string{"integer", "text", "text"},
+							Values:  [][]interface{}{{float64(0), "id", "INTEGER"}},
+						},
+					},
+				}
+				_ = json.NewEncoder(w).Encode(resp)
+			})
+			defer rqliteServer.Close()
 
-	if rec.Code != http.StatusOK {
-		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			req := httptest.NewRequest(http.MethodGet, "/columns?table="+url.QueryEscape(tt.table), nil)
+			rec := httptest.NewRecorder()
+			ds.handleColumns(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+		})
 	}
 }
 
@@ -195,3 +251,165 @@ func TestHandleTables_HidesBackendErrorDetails(t *testing.T) {
 		t.Fatalf("expected generic error message, got %q", rec.Body.String())
 	}
 }
+
+func TestHandleValues(t *testing.T) {
+	ds, rqliteServer := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		var statements []json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&statements); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+
+		var sql string
+		if err := json.Unmarshal(statements[0], &sql); err == nil {
+			switch {
+			case isSchemaQuery(sql):
+				_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{schemaResult("hosts")}})
+			case strings.HasPrefix(sql, "PRAGMA table_info"):
+				_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{{
+					Columns: []string{"cid", "name", "type"},
+					Values:  [][]interface{}{{float64(0), "name", "TEXT"}, {float64(1), "ts", "INTEGER"}},
+				}}})
+			default:
+				t.Fatalf("unexpected query: %q", sql)
+			}
+			return
+		}
+
+		var parameterized []interface{}
+		if err := json.Unmarshal(statements[0], &parameterized); err != nil {
+			t.Fatalf("failed to decode statement: %v", err)
+		}
+		expected := []interface{}{
+			`SELECT DISTINCT "name" FROM "hosts" WHERE "name" IS NOT NULL AND "name" LIKE ? ESCAPE '\' AND "ts" >= ? AND "ts" <= ? ORDER BY "name" LIMIT 5`,
+			"web%", float64(1000), float64(2000),
+		}
+		if !reflect.DeepEqual(parameterized, expected) {
+			t.Fatalf("unexpected statement: %v", parameterized)
+		}
+
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{{
+			Columns: []string{"name"},
+			Values:  [][]interface{}{{"web-1"}, {"web-2"}},
+		}}})
+	})
+	defer rqliteServer.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/values?table=hosts&column=name&prefix=web&limit=5&timeColumn=ts&from=1000000&to=2000000", nil)
+	rec := httptest.NewRecorder()
+	ds.handleValues(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var values []string
+	if err := json.NewDecoder(rec.Body).Decode(&values); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !reflect.DeepEqual(values, []string{"web-1", "web-2"}) {
+		t.Errorf("unexpected values: %v", values)
+	}
+}
+
+func TestHandleValues_RejectsUnknownColumn(t *testing.T) {
+	ds, rqliteServer := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		queries := decodeQueries(t, r)
+		if isSchemaQuery(queries[0]) {
+			_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{schemaResult("hosts")}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{{
+			Columns: []string{"cid", "name", "type"},
+			Values:  [][]interface{}{{float64(0), "name", "TEXT"}},
+		}}})
+	})
+	defer rqliteServer.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/values?table=hosts&column=password", nil)
+	rec := httptest.NewRecorder()
+	ds.handleValues(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "invalid column parameter") {
+		t.Fatalf("unexpected response body: %q", rec.Body.String())
+	}
+}
+
+func schemaColumnsResult() RqliteResult {
+	return RqliteResult{
+		Columns: []string{"name", "name", "type"},
+		Values: [][]interface{}{
+			{"cpu", "host", "TEXT"},
+			{"cpu", "value", "REAL"},
+			{"mem", "host", "TEXT"},
+			{"mem", "dc", "TEXT"},
+		},
+	}
+}
+
+func TestHandleTagKeys(t *testing.T) {
+	ds, rqliteServer := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		queries := decodeQueries(t, r)
+		if !strings.Contains(queries[0], "pragma_table_info") {
+			t.Fatalf("unexpected query: %q", queries[0])
+		}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{schemaColumnsResult()}})
+	})
+	defer rqliteServer.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/tag-keys", nil)
+	rec := httptest.NewRecorder()
+	ds.handleTagKeys(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var keys []string
+	if err := json.NewDecoder(rec.Body).Decode(&keys); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"dc", "host", "value"}) {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
+
+func TestHandleTagValues(t *testing.T) {
+	ds, rqliteServer := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		sql := decodeQueries(t, r)[0]
+		switch sql {
+		case `SELECT DISTINCT "host" FROM "cpu" WHERE "host" IS NOT NULL ORDER BY "host" LIMIT 100`:
+			_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{{
+				Values: [][]interface{}{{"web-1"}, {"web-2"}},
+			}}})
+		case `SELECT DISTINCT "host" FROM "mem" WHERE "host" IS NOT NULL ORDER BY "host" LIMIT 100`:
+			_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{{
+				Values: [][]interface{}{{"db-1"}, {"web-1"}},
+			}}})
+		default:
+			if !strings.Contains(sql, "pragma_table_info") {
+				t.Fatalf("unexpected query: %q", sql)
+			}
+			_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{schemaColumnsResult()}})
+		}
+	})
+	defer rqliteServer.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/tag-values?key=host", nil)
+	rec := httptest.NewRecorder()
+	ds.handleTagValues(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var values []string
+	if err := json.NewDecoder(rec.Body).Decode(&values); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !reflect.DeepEqual(values, []string{"db-1", "web-1", "web-2"}) {
+		t.Errorf("unexpected values: %v", values)
+	}
+}
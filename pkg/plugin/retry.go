@@ -0,0 +1,205 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultMaxRetries     = 3
+	defaultRetryBaseDelay = 100 * time.Millisecond
+	defaultRetryMaxDelay  = 2 * time.Second
+)
+
+// errLeadershipChange marks results that failed because the rqlite node lost
+// or did not hold leadership while the query ran.
+var errLeadershipChange = errors.New("rqlite leadership change")
+
+// RetryPolicy controls how queries are retried after transient failures.
+// The zero value disables retries.
+type RetryPolicy struct {
+	MaxRetries int           // retries after the first attempt
+	BaseDelay  time.Duration // upper bound of the first backoff delay
+	MaxDelay   time.Duration // upper bound of any backoff delay
+}
+
+// backoff returns the delay before the given retry, starting at 0, using
+// exponential backoff with full jitter.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	limit := p.BaseDelay << retry
+	if limit <= 0 || limit > p.MaxDelay {
+		limit = p.MaxDelay
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit)
+}
+
+// statusError is returned for non-OK responses from rqlite. Its message is
+// the generic query error, the details are logged by the client.
+type statusError struct {
+	StatusCode int
+}
+
+func (e *statusError) Error() string {
+	return genericQueryErrorMessage
+}
+
+// isTransient reports whether a failed query is worth retrying: the node
+// refused the connection, is unavailable, changed leadership, or the request
+// timed out. Failures after the query's own context ended are not retried.
+func isTransient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusServiceUnavailable
+	}
+	if errors.Is(err, errLeadershipChange) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// leadershipError returns an error wrapping errLeadershipChange if a result
+// of the response failed due to a leadership change.
+func leadershipError(resp *RqliteQueryResponse) error {
+	for _, result := range resp.Results {
+		msg := strings.ToLower(result.Error)
+		if strings.Contains(msg, "not leader") || strings.Contains(msg, "leadership lost") {
+			return fmt.Errorf("%w: %s", errLeadershipChange, result.Error)
+		}
+	}
+	return nil
+}
+
+// execute runs a query in a span with the given name and decodes its
+// response. The query is restricted to the context's tenant first. Transient
+// failures are retried with backoff as long as the retry fits in the
+// context's deadline. The number of retries made is recorded in the response.
+// If retries run out on a leadership change, the failed response is returned
+// as is.
+func (c *RqliteClient) execute(ctx context.Context, spanName, sql string, args []interface{}, decode func(io.Reader) (*RqliteQueryResponse, error)) (resp *RqliteQueryResponse, err error) {
+	sql, err = restrictTenant(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	ctx, span := tracing.DefaultTracer().Start(ctx, spanName, trace.WithAttributes(
+		attribute.String("rqlite.consistency_level", c.consistencyLevel),
+		attribute.String("rqlite.statement_fingerprint", sqlFingerprint(sql)),
+	))
+	defer func() {
+		if err != nil {
+			_ = tracing.Error(span, err)
+		} else {
+			rows := 0
+			for _, result := range resp.Results {
+				rows += result.RowCount
+			}
+			span.SetAttributes(
+				attribute.Int("rqlite.rows", rows),
+				attribute.Int64("rqlite.bytes_received", resp.BytesReceived),
+				attribute.Int("rqlite.retries", resp.Retries),
+			)
+		}
+		span.End()
+	}()
+
+	for retry := 0; ; retry++ {
+		resp, err := c.attempt(ctx, sql, args, decode)
+		if err == nil {
+			resp.Retries = retry
+			return resp, nil
+		}
+
+		if retry < c.retry.MaxRetries && isTransient(ctx, err) {
+			delay := c.retry.backoff(retry)
+			deadline, ok := ctx.Deadline()
+			if !ok || time.Now().Add(delay).Before(deadline) {
+				log.DefaultLogger.Warn("Retrying rqlite query after transient failure", "error", err, "retry", retry+1, "delay", delay)
+				if sleep(ctx, delay) {
+					continue
+				}
+			}
+		}
+
+		if resp != nil {
+			resp.Retries = retry
+			return resp, nil
+		}
+		return nil, err
+	}
+}
+
+// sleep waits for the delay and reports whether it elapsed before the
+// context ended.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// attempt runs a query once, within the client's concurrency limit and if
+// the circuit breaker allows it. A response whose results failed due to a
+// leadership change is returned along with an error.
+func (c *RqliteClient) attempt(ctx context.Context, sql string, args []interface{}, decode func(io.Reader) (*RqliteQueryResponse, error)) (*RqliteQueryResponse, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if c.breaker != nil {
+		if err := c.breaker.allow(); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	resp, err := c.roundTrip(ctx, sql, args, decode)
+	c.metrics.observeRequest(ctx, start, resp, err)
+	if c.breaker == nil {
+		return resp, err
+	}
+	if ctx.Err() != nil {
+		c.breaker.release()
+	} else {
+		c.breaker.record(err != nil && isFailure(err), time.Since(start))
+	}
+	return resp, err
+}
+
+func (c *RqliteClient) roundTrip(ctx context.Context, sql string, args []interface{}, decode func(io.Reader) (*RqliteQueryResponse, error)) (*RqliteQueryResponse, error) {
+	body, err := c.query(ctx, sql, args)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+
+	resp, err := decode(body)
+	if err != nil {
+		return nil, err
+	}
+	return resp, leadershipError(resp)
+}
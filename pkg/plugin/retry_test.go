@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for retry := 0; retry < 70; retry++ {
+		limit := min(p.BaseDelay<<min(retry, 10), p.MaxDelay)
+		if d := p.backoff(retry); d < 0 || d >= limit {
+			t.Errorf("backoff(%d) = %v, want in [0, %v)", retry, d, limit)
+		}
+	}
+	if d := (RetryPolicy{}).backoff(0); d != 0 {
+		t.Errorf("expected no delay for the zero policy, got %v", d)
+	}
+}
+
+func TestRqliteClient_RetryTransient(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure func(w http.ResponseWriter)
+	}{
+		{"unavailable", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}},
+		{"not leader", func(w http.ResponseWriter) {
+			_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{{Error: "not leader"}}})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= 2 {
+					tt.failure(w)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{{Columns: []string{"x"}}}})
+			}))
+			defer server.Close()
+
+			client := &RqliteClient{httpClient: server.Client(), baseURL: server.URL, consistencyLevel: "weak", retry: testRetryPolicy}
+			resp, err := client.Query(context.Background(), "SELECT 1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Retries != 2 || calls.Load() != 3 {
+				t.Errorf("expected 2 retries in 3 calls, got %d retries in %d calls", resp.Retries, calls.Load())
+			}
+		})
+	}
+}
+
+func TestRqliteClient_RetryExhausted(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{{Error: "leadership lost while committing"}}})
+	}))
+	defer server.Close()
+
+	client := &RqliteClient{httpClient: server.Client(), baseURL: server.URL, consistencyLevel: "weak", retry: testRetryPolicy}
+	resp, err := client.Query(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Results[0].Error != "leadership lost while committing" || resp.Retries != 3 || calls.Load() != 4 {
+		t.Errorf("unexpected result after %d calls: %+v", calls.Load(), resp)
+	}
+}
+
+func TestRqliteClient_NoRetryOnPermanentFailure(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := &RqliteClient{httpClient: server.Client(), baseURL: server.URL, consistencyLevel: "weak", retry: testRetryPolicy}
+	if _, err := client.Query(context.Background(), "SELECT 1"); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected a single call, got %d", calls.Load())
+	}
+}
+
+func TestRqliteClient_RetryConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	client := &RqliteClient{httpClient: http.DefaultClient, baseURL: url, consistencyLevel: "weak", retry: testRetryPolicy}
+	_, err := client.Query(context.Background(), "SELECT 1")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !isTransient(context.Background(), err) {
+		t.Errorf("expected connection refused to be transient: %v", err)
+	}
+}
+
+func TestRqliteClient_RetryWithinDeadline(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	policy := RetryPolicy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: time.Second}
+	client := &RqliteClient{httpClient: server.Client(), baseURL: server.URL, consistencyLevel: "weak", retry: policy}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := client.Query(ctx, "SELECT 1"); err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("retries exceeded the deadline, took %v", elapsed)
+	}
+}
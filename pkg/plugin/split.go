@@ -0,0 +1,377 @@
+package plugin
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// defaultSplitConcurrency is how many chunks of a split query run at once.
+	defaultSplitConcurrency = 4
+	// defaultChunkCacheSize is how many historical chunks are cached.
+	defaultChunkCacheSize = 100
+	// maxSplitChunks bounds the number of chunks a query is split into.
+	maxSplitChunks = 1000
+	// immutableChunkAge is how long after its end a chunk is assumed not to
+	// change anymore, so that it can be cached.
+	immutableChunkAge = time.Hour
+)
+
+// queryChunks returns the time ranges to run a query for when it is split,
+// or nil if it is not. Splitting requires a split duration and a time filter
+// macro to apply the chunks to.
+func queryChunks(qm QueryModel, query backend.DataQuery) ([]backend.TimeRange, error) {
+	if qm.SplitDuration == "" ||
+		!(timeFilterRegex.MatchString(qm.RawSQL) || unixEpochFilterRegex.MatchString(qm.RawSQL)) {
+		return nil, nil
+	}
+
+	seconds := parseInterval(qm.SplitDuration, query.Interval.Milliseconds())
+	if seconds <= 0 {
+		return nil, fmt.Errorf("invalid split duration %q", qm.SplitDuration)
+	}
+	chunks, err := splitTimeRange(query.TimeRange, time.Duration(seconds)*time.Second)
+	if err != nil || len(chunks) < 2 {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// splitTimeRange splits a time range into chunks aligned to multiples of
+// step since the Unix epoch, so that chunks are reused by later queries with
+// a moving range. Each chunk ends where the next one starts; the time filters
+// of all chunks but the last exclude their end, so that every time, including
+// fractional ones, falls into exactly one chunk.
+func splitTimeRange(timeRange backend.TimeRange, step time.Duration) ([]backend.TimeRange, error) {
+	stepSec := int64(step / time.Second)
+	from, to := timeRange.From.Unix(), timeRange.To.Unix()
+	if (to-from)/stepSec >= maxSplitChunks {
+		return nil, fmt.Errorf("split duration %s would split the time range into more than %d chunks", step, maxSplitChunks)
+	}
+
+	var chunks []backend.TimeRange
+	for start := from; ; {
+		end := (start/stepSec + 1) * stepSec
+		if end >= to {
+			return append(chunks, backend.TimeRange{From: time.Unix(start, 0), To: time.Unix(to, 0)}), nil
+		}
+		chunks = append(chunks, backend.TimeRange{From: time.Unix(start, 0), To: time.Unix(end, 0)})
+		start = end
+	}
+}
+
+// chunkResult is the outcome of one chunk of a split query.
+type chunkResult struct {
+	resp   *RqliteQueryResponse
+	cached bool
+}
+
+// querySplit runs a query once per chunk of its time range with bounded
+// concurrency, and merges the chunk frames into a single result ordered by
+// time. Chunks that ended long enough ago are served from and stored in the
+// chunk cache.
+func (d *Datasource) querySplit(ctx context.Context, qm QueryModel, query backend.DataQuery, chunks []backend.TimeRange) (*RqliteQueryResponse, error) {
+	concurrency := d.settings.SplitConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultSplitConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chunkResult, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			results[i], errs[i] = d.queryChunk(ctx, qm, query, chunk, i < len(chunks)-1)
+			if errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Prefer the error that caused the cancellation over context errors.
+	var ctxErr error
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, context.Canceled):
+			ctxErr = err
+		default:
+			return nil, err
+		}
+	}
+	if ctxErr != nil {
+		return nil, ctxErr
+	}
+
+	return d.mergeChunks(results, qm.frameOptions())
+}
+
+// queryChunk runs a query for one chunk of its time range. With openEnd, the
+// time filters exclude the end of the chunk.
+func (d *Datasource) queryChunk(ctx context.Context, qm QueryModel, query backend.DataQuery, chunk backend.TimeRange, openEnd bool) (chunkResult, error) {
+	sql, args, err := d.expandSQL(ctx, &qm, applyMacros(qm.RawSQL, chunk, query.Interval.Milliseconds(), openEnd))
+	if err != nil {
+		return chunkResult{}, err
+	}
+
+	cacheable := d.chunkCache != nil && chunk.To.Before(time.Now().Add(-immutableChunkAge))
+	var key string
+	if cacheable {
+		key, err = chunkCacheKey(userScope(ctx), sql, args, qm.frameOptions())
+		if err != nil {
+			return chunkResult{}, err
+		}
+		resp, ok := d.chunkCache.get(key)
+		d.metrics.observeChunkCache(ok)
+		if ok {
+			return chunkResult{resp: resp, cached: true}, nil
+		}
+	}
+
+	resp, err := d.client.QueryFrame(ctx, sql, qm.frameOptions(), args...)
+	if err != nil {
+		return chunkResult{}, err
+	}
+	if len(resp.Results) > 0 && resp.Results[0].Error != "" {
+		return chunkResult{}, fmt.Errorf("rqlite query error: %s", resp.Results[0].Error)
+	}
+	if cacheable && resp.Truncated == "" {
+		d.chunkCache.put(key, resp)
+	}
+	return chunkResult{resp: resp}, nil
+}
+
+// mergeChunks combines the chunk responses into one response whose first
+// result holds the merged frame. The row limit applies to the merged rows.
+// Time columns are detected on the merged rows, so that they can be sorted
+// by time and all chunks get the same field types.
+func (d *Datasource) mergeChunks(results []chunkResult, opts FrameOptions) (*RqliteQueryResponse, error) {
+	merged := &RqliteQueryResponse{Chunks: len(results)}
+	var frame *data.Frame
+	var types []string
+	var serverTime float64
+	for _, r := range results {
+		if r.cached {
+			merged.CachedChunks++
+		} else {
+			merged.Time += r.resp.Time
+			merged.BytesReceived += r.resp.BytesReceived
+			merged.Retries += r.resp.Retries
+		}
+		if r.resp.Truncated != "" {
+			merged.Truncated = r.resp.Truncated
+		}
+		if len(r.resp.Results) == 0 || r.resp.Results[0].Frame == nil {
+			continue
+		}
+
+		result := r.resp.Results[0]
+		if !r.cached {
+			serverTime += result.Time
+		}
+		if frame == nil {
+			frame = emptyFrameLike(result.Frame)
+		}
+		if types == nil {
+			types = result.Types
+		}
+		if err := appendFrameRows(frame, result.Frame); err != nil {
+			return nil, err
+		}
+		if result.Frame.Meta != nil {
+			frame.AppendNotices(result.Frame.Meta.Notices...)
+		}
+	}
+	if frame == nil {
+		return merged, nil
+	}
+
+	if opts.detectsTimeColumns() {
+		detectTimeFields(frame, types)
+	}
+	frame = sortFrameByTime(frame)
+
+	limits := d.client.limits
+	if limits.MaxRows > 0 && int64(frame.Rows()) > limits.MaxRows {
+		if limits.Reject {
+			return nil, fmt.Errorf("%w: more than %d rows", errResultLimit, limits.MaxRows)
+		}
+		frame = sliceFrame(frame, int(limits.MaxRows))
+		merged.Truncated = fmt.Sprintf("row limit of %d rows", limits.MaxRows)
+	}
+
+	merged.Results = []RqliteResult{{Frame: frame, Types: types, RowCount: frame.Rows(), Time: serverTime}}
+	return merged, nil
+}
+
+// emptyFrameLike returns a frame with empty fields of the same names and
+// types as frame.
+func emptyFrameLike(frame *data.Frame) *data.Frame {
+	out := data.NewFrame(frame.Name)
+	for _, f := range frame.Fields {
+		field := data.NewFieldFromFieldType(f.Type(), 0)
+		field.Name = f.Name
+		out.Fields = append(out.Fields, field)
+	}
+	return out
+}
+
+// appendFrameRows appends the rows of src to dst. Chunks without rows are
+// skipped, since their column types may not be known.
+func appendFrameRows(dst, src *data.Frame) error {
+	if src.Rows() == 0 {
+		return nil
+	}
+	if len(dst.Fields) != len(src.Fields) {
+		return errors.New("chunk results have different columns")
+	}
+	for i, f := range src.Fields {
+		if dst.Fields[i].Type() != f.Type() {
+			if dst.Fields[i].Len() > 0 {
+				return fmt.Errorf("chunk results have different types for column %q", f.Name)
+			}
+			// Replace the field of earlier empty chunks.
+			dst.Fields[i] = data.NewFieldFromFieldType(f.Type(), 0)
+			dst.Fields[i].Name = f.Name
+		}
+	}
+	for i, f := range src.Fields {
+		for row := 0; row < f.Len(); row++ {
+			dst.Fields[i].Append(f.CopyAt(row))
+		}
+	}
+	return nil
+}
+
+// sortFrameByTime stably sorts the rows of a frame by its first time field,
+// with null times last, keeping its metadata. Frames without a time field
+// are returned unchanged.
+func sortFrameByTime(frame *data.Frame) *data.Frame {
+	timeIdx := -1
+	for i, f := range frame.Fields {
+		if f.Type().Time() {
+			timeIdx = i
+			break
+		}
+	}
+	if timeIdx < 0 {
+		return frame
+	}
+
+	timeField := frame.Fields[timeIdx]
+	order := make([]int, frame.Rows())
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ta, okA := timeField.ConcreteAt(order[a])
+		tb, okB := timeField.ConcreteAt(order[b])
+		if !okA || !okB {
+			return okA && !okB
+		}
+		return ta.(time.Time).Before(tb.(time.Time))
+	})
+
+	out := emptyFrameLike(frame)
+	out.Meta = frame.Meta
+	for i, f := range frame.Fields {
+		out.Fields[i].Extend(len(order))
+		for row, src := range order {
+			out.Fields[i].Set(row, f.CopyAt(src))
+		}
+	}
+	return out
+}
+
+// sliceFrame returns a frame with the first n rows of frame.
+func sliceFrame(frame *data.Frame, n int) *data.Frame {
+	out := emptyFrameLike(frame)
+	out.Meta = frame.Meta
+	for i, f := range frame.Fields {
+		for row := 0; row < n; row++ {
+			out.Fields[i].Append(f.CopyAt(row))
+		}
+	}
+	return out
+}
+
+// chunkCacheKey identifies a chunk response. Responses are cached per user
+// scope, as users may see different rows.
+func chunkCacheKey(scope, sql string, args []interface{}, opts FrameOptions) (string, error) {
+	b, err := json.Marshal([]interface{}{scope, sql, args, opts})
+	if err != nil {
+		return "", fmt.Errorf("building cache key: %w", err)
+	}
+	return string(b), nil
+}
+
+// chunkCache is a least recently used cache of chunk responses.
+type chunkCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+}
+
+type chunkCacheEntry struct {
+	key  string
+	resp *RqliteQueryResponse
+}
+
+func newChunkCache(size int) *chunkCache {
+	return &chunkCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *chunkCache) get(key string) (*RqliteQueryResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*chunkCacheEntry).resp, true
+}
+
+func (c *chunkCache) put(key string, resp *RqliteQueryResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*chunkCacheEntry).resp = resp
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&chunkCacheEntry{key: key, resp: resp})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*chunkCacheEntry).key)
+	}
+}
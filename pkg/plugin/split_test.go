@@ -0,0 +1,262 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestSplitTimeRange(t *testing.T) {
+	day := 24 * time.Hour
+	from := time.Unix(1700000000, 0) // 2023-11-14 22:13:20 UTC
+	to := from.Add(2 * day)
+
+	chunks, err := splitTimeRange(backend.TimeRange{From: from, To: to}, day)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := [][2]int64{
+		{1700000000, 1700006400},
+		{1700006400, 1700092800},
+		{1700092800, 1700172800},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("expected %d chunks, got %d", len(want), len(chunks))
+	}
+	for i, c := range chunks {
+		if c.From.Unix() != want[i][0] || c.To.Unix() != want[i][1] {
+			t.Errorf("chunk %d: got %d-%d, want %d-%d", i, c.From.Unix(), c.To.Unix(), want[i][0], want[i][1])
+		}
+	}
+
+	if _, err := splitTimeRange(backend.TimeRange{From: from, To: from.Add(90 * day)}, time.Minute); err == nil {
+		t.Error("expected an error for too many chunks")
+	}
+}
+
+func TestQueryChunks(t *testing.T) {
+	query := backend.DataQuery{TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(3*86400, 0)}}
+
+	tests := []struct {
+		name    string
+		qm      QueryModel
+		chunks  int
+		wantErr bool
+	}{
+		{"no split duration", QueryModel{RawSQL: "SELECT * FROM t WHERE $__timeFilter(time)"}, 0, false},
+		{"no time filter", QueryModel{RawSQL: "SELECT * FROM t", SplitDuration: "1d"}, 0, false},
+		{"split", QueryModel{RawSQL: "SELECT * FROM t WHERE $__timeFilter(time)", SplitDuration: "1d"}, 3, false},
+		{"single chunk", QueryModel{RawSQL: "SELECT * FROM t WHERE $__timeFilter(time)", SplitDuration: "7d"}, 0, false},
+		{"invalid", QueryModel{RawSQL: "SELECT * FROM t WHERE $__timeFilter(time)", SplitDuration: "soon"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := queryChunks(tt.qm, query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(chunks) != tt.chunks {
+				t.Errorf("expected %d chunks, got %d", tt.chunks, len(chunks))
+			}
+		})
+	}
+}
+
+func TestDatasource_SplitQuery(t *testing.T) {
+	rangeRegex := regexp.MustCompile(`time >= (\d+) AND time (<=?) (\d+)`)
+	var requests, closed atomic.Int32
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var stmts []string
+		_ = json.NewDecoder(r.Body).Decode(&stmts)
+		m := rangeRegex.FindStringSubmatch(stmts[0])
+		from, _ := strconv.ParseFloat(m[1], 64)
+		to, _ := strconv.ParseFloat(m[3], 64)
+		if m[2] == "<=" {
+			closed.Add(1)
+		} else {
+			// The end of the chunk belongs to the next one.
+			to -= 0.5
+		}
+
+		// One row at the end and one at the start of each chunk, in
+		// descending order.
+		result := RqliteResult{
+			Columns: []string{"time", "value"},
+			Types:   []string{"integer", "real"},
+			Values:  [][]interface{}{{to, to - from}, {from, 0.0}},
+		}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+	ds.chunkCache = newChunkCache(10)
+
+	from := time.Now().Add(-10 * 24 * time.Hour).Truncate(time.Second)
+	qm := QueryModel{
+		RawSQL:        "SELECT time, value FROM metrics WHERE $__timeFilter(time)",
+		Format:        "table",
+		TimeColumns:   []TimeColumn{{Name: "time"}},
+		SplitDuration: "1d",
+	}
+	qmJSON, _ := json.Marshal(qm)
+	req := &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      qmJSON,
+			TimeRange: backend.TimeRange{From: from, To: from.Add(3 * 24 * time.Hour)},
+		}},
+	}
+
+	resp, err := ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatalf("unexpected response error: %v", res.Error)
+	}
+	frame := res.Frames[0]
+	chunks := int(requests.Load())
+	if chunks != 4 && chunks != 3 {
+		t.Fatalf("expected 3 or 4 chunk queries, got %d", chunks)
+	}
+	if frame.Rows() != 2*chunks {
+		t.Fatalf("expected %d rows, got %d", 2*chunks, frame.Rows())
+	}
+	var prev time.Time
+	for i := 0; i < frame.Rows(); i++ {
+		ts, _ := frame.Fields[0].ConcreteAt(i)
+		if ts.(time.Time).Before(prev) {
+			t.Fatalf("rows not ordered by time at row %d", i)
+		}
+		prev = ts.(time.Time)
+	}
+	if closed.Load() != 1 {
+		t.Errorf("expected only the last chunk to include its end, got %d", closed.Load())
+	}
+	assertStat(t, frame, "Chunks", float64(chunks))
+	assertStat(t, frame, "Cached chunks", 0)
+
+	// The chunks ended more than an hour ago, so they are cached now.
+	if _, err := ds.QueryData(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := int(requests.Load()); got != chunks {
+		t.Errorf("expected cached chunks to be reused, got %d more queries", got-chunks)
+	}
+}
+
+func TestDatasource_SplitQueryRowLimit(t *testing.T) {
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		result := RqliteResult{
+			Columns: []string{"time"},
+			Types:   []string{"integer"},
+			Values:  [][]interface{}{{float64(1)}, {float64(2)}, {float64(3)}},
+		}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+	ds.client.limits = ResultLimits{MaxRows: 5}
+
+	qm := QueryModel{RawSQL: "SELECT time FROM t WHERE $__timeFilter(time)", SplitDuration: "1d"}
+	tr := backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(2*86400, 0)}
+	resp, err := ds.querySplit(context.Background(), qm, backend.DataQuery{TimeRange: tr}, mustChunks(t, qm, tr))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rows := resp.Results[0].Frame.Rows(); rows != 5 {
+		t.Errorf("expected 5 rows, got %d", rows)
+	}
+	if resp.Truncated != "row limit of 5 rows" {
+		t.Errorf("unexpected truncation: %q", resp.Truncated)
+	}
+}
+
+func TestChunkCache(t *testing.T) {
+	c := newChunkCache(2)
+	a, b, d := &RqliteQueryResponse{}, &RqliteQueryResponse{}, &RqliteQueryResponse{}
+	c.put("a", a)
+	c.put("b", b)
+	c.get("a")
+	c.put("d", d)
+
+	if got, ok := c.get("a"); !ok || got != a {
+		t.Error("expected recently used entry to be kept")
+	}
+	if _, ok := c.get("b"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+}
+
+func mustChunks(t *testing.T, qm QueryModel, tr backend.TimeRange) []backend.TimeRange {
+	t.Helper()
+	chunks, err := queryChunks(qm, backend.DataQuery{TimeRange: tr})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return chunks
+}
+
+func assertStat(t *testing.T, frame *data.Frame, name string, want float64) {
+	t.Helper()
+	for _, s := range frame.Meta.Stats {
+		if s.DisplayName == name {
+			if s.Value != want {
+				t.Errorf("stat %q: got %v, want %v", name, s.Value, want)
+			}
+			return
+		}
+	}
+	t.Errorf("stat %q not found", name)
+}
+
+func TestDatasource_SplitQueryAdhocProbe(t *testing.T) {
+	var probes, chunks atomic.Int32
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		var stmts []string
+		_ = json.NewDecoder(r.Body).Decode(&stmts)
+		if strings.HasSuffix(stmts[0], "LIMIT 0") {
+			probes.Add(1)
+		} else {
+			chunks.Add(1)
+		}
+		result := RqliteResult{Columns: []string{"time", "host"}, Types: []string{"integer", "text"}}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+
+	qm := QueryModel{
+		RawSQL:        "SELECT time, host FROM metrics WHERE $__timeFilter(time)",
+		SplitDuration: "1d",
+		AdhocFilters:  []AdhocFilter{{Key: "host", Operator: "=", Value: "web-1"}},
+	}
+	qmJSON, _ := json.Marshal(qm)
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      qmJSON,
+			TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(3*86400, 0)},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res := resp.Responses["A"]; res.Error != nil {
+		t.Fatalf("unexpected response error: %v", res.Error)
+	}
+	if chunks.Load() != 3 {
+		t.Errorf("expected 3 chunk queries, got %d", chunks.Load())
+	}
+	if probes.Load() != 1 {
+		t.Errorf("expected the filter columns to be probed once, got %d probes", probes.Load())
+	}
+}
@@ -0,0 +1,342 @@
+package plugin
+
+import (
+	"strings"
+)
+
+type sqlTokenKind int
+
+const (
+	tokenWord   sqlTokenKind = iota // keyword or bare identifier
+	tokenQuoted                     // "quoted", `quoted` or [quoted] identifier
+	tokenString                     // string or blob literal
+	tokenNumber
+	tokenPunct
+)
+
+// sqlToken is a token of a SQL statement, with its byte offsets in the
+// statement.
+type sqlToken struct {
+	kind       sqlTokenKind
+	text       string
+	start, end int
+}
+
+// name returns the identifier the token stands for, unquoted.
+func (t sqlToken) name() string {
+	if t.kind != tokenQuoted {
+		return t.text
+	}
+	inner := t.text[1 : len(t.text)-1]
+	if t.text[0] == '[' {
+		return inner
+	}
+	q := t.text[:1]
+	return strings.ReplaceAll(inner, q+q, q)
+}
+
+// isIdent reports whether the token can be an identifier.
+func (t sqlToken) isIdent() bool {
+	return t.kind == tokenQuoted || (t.kind == tokenWord && !isDigit(t.text[0]))
+}
+
+// is reports whether the token is the given punctuation or, ignoring case,
+// the given keyword.
+func (t sqlToken) is(s string) bool {
+	return (t.kind == tokenWord || t.kind == tokenPunct) && strings.EqualFold(t.text, s)
+}
+
+// tokenizeSQL splits a statement into tokens, skipping whitespace and
+// comments.
+func tokenizeSQL(sql string) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		start := i
+		kind := tokenPunct
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			i++
+			continue
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+			continue
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(sql)
+			}
+			continue
+		case c == '\'' || ((c == 'x' || c == 'X') && i+1 < len(sql) && sql[i+1] == '\''):
+			if c != '\'' {
+				i++
+			}
+			i = skipQuoted(sql, i, '\'')
+			kind = tokenString
+		case c == '"' || c == '`' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			i = skipQuoted(sql, i, end)
+			kind = tokenQuoted
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			i = skipNumber(sql, i)
+			kind = tokenNumber
+		case isIdentChar(c):
+			for i < len(sql) && isIdentChar(sql[i]) {
+				i++
+			}
+			kind = tokenWord
+		default:
+			i++
+		}
+		tokens = append(tokens, sqlToken{kind: kind, text: sql[start:i], start: start, end: i})
+	}
+	return tokens
+}
+
+// tableRef is a reference to a table or view in the FROM clause of a
+// statement, or the right operand of an IN operator. Start and End are the
+// byte offsets of the possibly schema-qualified name, Aliased tells whether
+// an alias follows it. Operands of IN take no alias. Unresolved references
+// are tokens where a table is expected that are no table name, such as
+// string literals, which SQLite may still take for one; their Name is the
+// token's text.
+type tableRef struct {
+	Schema     string
+	Name       string
+	Start, End int
+	Aliased    bool
+	Operand    bool
+	Unresolved bool
+}
+
+// commonTable is a common table expression of a WITH clause. References to
+// its name resolve to it after its definition and, if recursive, within it,
+// up to the end of the enclosing statement.
+type commonTable struct {
+	name           string
+	start, bodyEnd int
+	scopeEnd       int
+	recursive      bool
+}
+
+// fromClauseEnd holds the keywords that end a FROM clause.
+var fromClauseEnd = map[string]bool{
+	"WHERE": true, "GROUP": true, "HAVING": true, "ORDER": true, "LIMIT": true,
+	"WINDOW": true, "UNION": true, "INTERSECT": true, "EXCEPT": true, "SELECT": true,
+	"VALUES": true, "RETURNING": true,
+}
+
+// notAlias holds the keywords that may follow a table name without being
+// its alias.
+var notAlias = map[string]bool{
+	"WHERE": true, "GROUP": true, "HAVING": true, "ORDER": true, "LIMIT": true,
+	"WINDOW": true, "UNION": true, "INTERSECT": true, "EXCEPT": true, "JOIN": true,
+	"LEFT": true, "RIGHT": true, "FULL": true, "INNER": true, "CROSS": true,
+	"NATURAL": true, "OUTER": true, "ON": true, "USING": true, "INDEXED": true,
+	"NOT": true, "RETURNING": true, "OFFSET": true,
+}
+
+// tableRefs returns the references to tables and views in a statement,
+// including those in subqueries, joins, common table expressions and on the
+// right of IN, as in "x IN t", but not references to the common tables
+// themselves. Table-valued functions such as json_each are not tables and
+// are left out. Tokens after FROM, JOIN or a comma of a FROM clause that are
+// neither a name nor a parenthesis are returned as unresolved references, so
+// that callers can reject the statement.
+func tableRefs(sql string) []tableRef {
+	tokens := tokenizeSQL(sql)
+	ctes := commonTables(tokens, len(sql))
+
+	var refs []tableRef
+	depth := 0
+	inFrom := map[int]bool{}
+	expectTable := false
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.is("("):
+			// A parenthesized join in a FROM clause holds table names
+			// without a FROM keyword of its own.
+			join := expectTable && i+1 < len(tokens) &&
+				!tokens[i+1].is("SELECT") && !tokens[i+1].is("WITH") && !tokens[i+1].is("VALUES")
+			depth++
+			inFrom[depth] = join
+			expectTable = join
+		case t.is(")"):
+			inFrom[depth] = false
+			depth--
+			expectTable = false
+		case t.is("FROM") && i > 0 && tokens[i-1].is("DISTINCT"):
+			// IS [NOT] DISTINCT FROM compares values.
+			expectTable = false
+		case t.is("FROM"):
+			inFrom[depth] = true
+			expectTable = true
+		case t.is("JOIN"):
+			expectTable = true
+		case t.is(","):
+			expectTable = inFrom[depth]
+		case t.kind == tokenWord && fromClauseEnd[strings.ToUpper(t.text)]:
+			inFrom[depth] = false
+			expectTable = false
+		case t.is("IN") && i+1 < len(tokens) && tokens[i+1].isIdent():
+			expectTable = false
+			var ref tableRef
+			ref, i = readTableName(tokens, i+1)
+			ref.Operand = true
+			if i+1 < len(tokens) && tokens[i+1].is("(") {
+				continue // table-valued function
+			}
+			if ref.Schema == "" && isCommonTable(ctes, ref) {
+				continue
+			}
+			refs = append(refs, ref)
+		case expectTable && t.isIdent():
+			expectTable = false
+			var ref tableRef
+			ref, i = readTableName(tokens, i)
+			if i+1 < len(tokens) && tokens[i+1].is("(") {
+				continue // table-valued function
+			}
+			if i+1 < len(tokens) {
+				next := tokens[i+1]
+				ref.Aliased = next.is("AS") || next.kind == tokenQuoted ||
+					(next.isIdent() && !notAlias[strings.ToUpper(next.text)])
+			}
+			if ref.Schema == "" && isCommonTable(ctes, ref) {
+				continue
+			}
+			refs = append(refs, ref)
+		case expectTable:
+			expectTable = false
+			refs = append(refs, tableRef{Name: t.text, Start: t.start, End: t.end, Unresolved: true})
+		}
+	}
+	return refs
+}
+
+// readTableName reads the possibly schema-qualified name starting at token i
+// and returns its reference and the index of its last token.
+func readTableName(tokens []sqlToken, i int) (tableRef, int) {
+	ref := tableRef{Name: tokens[i].name(), Start: tokens[i].start, End: tokens[i].end}
+	if i+2 < len(tokens) && tokens[i+1].is(".") && tokens[i+2].isIdent() {
+		i += 2
+		ref.Schema, ref.Name, ref.End = ref.Name, tokens[i].name(), tokens[i].end
+	}
+	return ref, i
+}
+
+// referencedTables returns the distinct names of the tables and views a
+// statement reads from.
+func referencedTables(sql string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, ref := range tableRefs(sql) {
+		if ref.Unresolved {
+			continue
+		}
+		if key := strings.ToLower(ref.Name); !seen[key] {
+			seen[key] = true
+			names = append(names, ref.Name)
+		}
+	}
+	return names
+}
+
+// commonTables finds the common table expressions of the WITH clauses of a
+// tokenized statement of length n.
+func commonTables(tokens []sqlToken, n int) []commonTable {
+	var ctes []commonTable
+	for i := range tokens {
+		if !tokens[i].is("WITH") {
+			continue
+		}
+		scope := scopeEnd(tokens, i, n)
+		j := i
+		recursive := j+1 < len(tokens) && tokens[j+1].is("RECURSIVE")
+		if recursive {
+			j++
+		}
+		for j+1 < len(tokens) && tokens[j+1].isIdent() {
+			j++
+			cte := commonTable{name: tokens[j].name(), start: tokens[j].start, scopeEnd: scope, recursive: recursive}
+			if j+1 < len(tokens) && tokens[j+1].is("(") {
+				j = matchingParen(tokens, j+1) // column names
+			}
+			for j+1 < len(tokens) && !tokens[j+1].is("(") {
+				j++ // AS [NOT] MATERIALIZED
+			}
+			if j+1 >= len(tokens) {
+				break
+			}
+			j = matchingParen(tokens, j+1)
+			cte.bodyEnd = n
+			if j < len(tokens) {
+				cte.bodyEnd = tokens[j].end
+			}
+			ctes = append(ctes, cte)
+			if j+1 >= len(tokens) || !tokens[j+1].is(",") {
+				break
+			}
+			j++
+		}
+	}
+	return ctes
+}
+
+// isCommonTable reports whether a table reference resolves to a common table.
+func isCommonTable(ctes []commonTable, ref tableRef) bool {
+	for _, cte := range ctes {
+		if !strings.EqualFold(cte.name, ref.Name) || ref.Start >= cte.scopeEnd {
+			continue
+		}
+		if ref.Start >= cte.bodyEnd || (cte.recursive && ref.Start > cte.start) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingParen returns the index of the parenthesis closing the one at
+// index i, or len(tokens) if it is not closed.
+func matchingParen(tokens []sqlToken, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch {
+		case tokens[i].is("("):
+			depth++
+		case tokens[i].is(")"):
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// scopeEnd returns the byte offset where the parenthesized scope containing
+// token i ends, or n if it is at the top level.
+func scopeEnd(tokens []sqlToken, i, n int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch {
+		case tokens[i].is("("):
+			depth++
+		case tokens[i].is(")"):
+			if depth == 0 {
+				return tokens[i].start
+			}
+			depth--
+		}
+	}
+	return n
+}
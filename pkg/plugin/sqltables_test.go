@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferencedTables(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{"simple", "SELECT * FROM metrics", []string{"metrics"}},
+		{"quoted and qualified", `SELECT * FROM main."my table" AS t JOIN [other] o ON t.id = o.id`, []string{"my table", "other"}},
+		{"comma join", "SELECT * FROM a, b x, c WHERE a.id = b.id ORDER BY a.id, b.id", []string{"a", "b", "c"}},
+		{"joins", "SELECT * FROM a LEFT OUTER JOIN b ON a.x = b.x, c NATURAL JOIN d", []string{"a", "b", "c", "d"}},
+		{"subqueries", "SELECT (SELECT max(v) FROM s) FROM (SELECT * FROM inner_t) WHERE id IN (SELECT id FROM w)", []string{"s", "inner_t", "w"}},
+		{"parenthesized join", "SELECT * FROM (a JOIN b USING (id, ts))", []string{"a", "b"}},
+		{"union", "SELECT x FROM a UNION ALL SELECT x FROM b", []string{"a", "b"}},
+		{"table-valued function", "SELECT * FROM t, json_each(t.tags)", []string{"t"}},
+		{"cte", "WITH recent(id) AS (SELECT id FROM events), other AS MATERIALIZED (SELECT * FROM recent) SELECT * FROM other JOIN users", []string{"events", "users"}},
+		{"cte shadowing a table", "WITH secrets AS (SELECT * FROM secrets WHERE public) SELECT * FROM secrets", []string{"secrets"}},
+		{"recursive cte", "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT * FROM n", nil},
+		{"cte out of scope", "SELECT * FROM (WITH x AS (SELECT 1) SELECT * FROM x), x", []string{"x"}},
+		{"literals and comments", "SELECT 'FROM fake' FROM real -- FROM comment\n/* FROM block */", []string{"real"}},
+		{"in table", "SELECT 'acme' IN secrets, x NOT IN main.keys FROM t WHERE y IN (a, b)", []string{"secrets", "keys", "t"}},
+		{"in cte and function", "WITH c AS (SELECT 1) SELECT 1 IN c, 2 IN json_each('[2]')", nil},
+		{"form feed and vertical tab", "SELECT * FROM\fa JOIN\vb ON 1,\fc", []string{"a", "b", "c"}},
+		{"distinct from", "SELECT * FROM a WHERE x IS DISTINCT FROM y", []string{"a"}},
+		{"no tables", "SELECT 1, 'a'", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := referencedTables(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableRefs_InOperand(t *testing.T) {
+	refs := tableRefs("SELECT 1 IN main.secrets")
+	if len(refs) != 1 || !refs[0].Operand || refs[0].Schema != "main" || refs[0].Name != "secrets" {
+		t.Fatalf("expected the IN operand main.secrets, got %+v", refs)
+	}
+}
+
+func TestTableRefs_Unresolved(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT * FROM 'secrets'",
+		"SELECT * FROM a JOIN 'secrets' ON 1",
+		"SELECT * FROM a, 'secrets'",
+		"SELECT * FROM\x00secrets",
+	} {
+		refs := tableRefs(sql)
+		if len(refs) == 0 || !refs[len(refs)-1].Unresolved {
+			t.Errorf("%q: expected an unresolved reference, got %+v", sql, refs)
+		}
+	}
+}
+
+func TestTableRefs_Aliased(t *testing.T) {
+	refs := tableRefs("SELECT * FROM a JOIN b AS y ON a.id = y.id JOIN c z WHERE 1")
+	want := []bool{false, true, true}
+	if len(refs) != len(want) {
+		t.Fatalf("expected %d refs, got %v", len(want), refs)
+	}
+	for i, ref := range refs {
+		if ref.Aliased != want[i] {
+			t.Errorf("%s: expected aliased %v", ref.Name, want[i])
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// errTableAccess is returned for queries and resource calls on tables that
+// the data source does not expose.
+var errTableAccess = errors.New("access denied by the data source's table settings")
+
+// tableAccess restricts the tables a data source exposes by glob patterns,
+// matched case-insensitively as by path.Match. A table is exposed if it
+// matches no denied pattern and, if there are allowed patterns, at least one
+// of them. Schema objects, which describe hidden tables too, are only exposed
+// if an allowed pattern names them exactly. A nil *tableAccess exposes all
+// tables.
+type tableAccess struct {
+	allowed []string
+	denied  []string
+}
+
+// newTableAccess validates the patterns and returns the table access for
+// them, or nil if there are none.
+func newTableAccess(allowed, denied []string) (*tableAccess, error) {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return nil, nil
+	}
+	a := &tableAccess{}
+	for _, p := range allowed {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid allowed table pattern %q", p)
+		}
+		a.allowed = append(a.allowed, strings.ToLower(p))
+	}
+	for _, p := range denied {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid denied table pattern %q", p)
+		}
+		a.denied = append(a.denied, strings.ToLower(p))
+	}
+	return a, nil
+}
+
+// allows reports whether the table is exposed.
+func (a *tableAccess) allows(table string) bool {
+	if a == nil {
+		return true
+	}
+	table = strings.ToLower(table)
+	for _, p := range a.denied {
+		if ok, _ := path.Match(p, table); ok {
+			return false
+		}
+	}
+	if isSchemaObject(table) {
+		return slices.Contains(a.allowed, table)
+	}
+	if len(a.allowed) == 0 {
+		return true
+	}
+	for _, p := range a.allowed {
+		if ok, _ := path.Match(p, table); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedRef returns the first reference of a statement to a table that is
+// not exposed. Unresolved references are blocked, as the table they name is
+// unknown.
+func (a *tableAccess) blockedRef(sql string) (tableRef, bool) {
+	if a == nil {
+		return tableRef{}, false
+	}
+	for _, ref := range append(tableRefs(sql), pragmaRefs(sql)...) {
+		if ref.Unresolved || !a.allows(ref.Name) {
+			return ref, true
+		}
+	}
+	return tableRef{}, false
+}
+
+// isSchemaObject reports whether a lowercase name is a schema table, such as
+// sqlite_master, or a pragma function, such as pragma_table_info.
+func isSchemaObject(name string) bool {
+	return strings.HasPrefix(name, "sqlite_") || strings.HasPrefix(name, "pragma_")
+}
+
+// pragmaRefs returns the pragma functions of a statement, such as
+// pragma_table_info('t'), and its pragma if it is a PRAGMA statement, named
+// like the function of the pragma.
+func pragmaRefs(sql string) []tableRef {
+	tokens := tokenizeSQL(sql)
+	var refs []tableRef
+	for i, t := range tokens {
+		switch {
+		case i == 0 && t.is("PRAGMA") && len(tokens) > 1:
+			ref, _ := readTableName(tokens, 1)
+			ref.Name = "pragma_" + ref.Name
+			refs = append(refs, ref)
+		case t.kind == tokenWord && strings.HasPrefix(strings.ToLower(t.text), "pragma_") &&
+			i+1 < len(tokens) && tokens[i+1].is("("):
+			refs = append(refs, tableRef{Name: t.text, Start: t.start, End: t.end})
+		}
+	}
+	return refs
+}
+
+// check returns an error wrapping errTableAccess if a statement reads from a
+// table that is not exposed.
+func (a *tableAccess) check(sql string) error {
+	if ref, ok := a.blockedRef(sql); ok {
+		return refAccessError(ref)
+	}
+	return nil
+}
+
+func tableAccessError(table string) error {
+	return fmt.Errorf("table %q: %w", table, errTableAccess)
+}
+
+// refAccessError returns the error for a blocked table reference.
+func refAccessError(ref tableRef) error {
+	if ref.Unresolved {
+		return fmt.Errorf("unrecognized table reference %q: %w", ref.Name, errTableAccess)
+	}
+	return tableAccessError(ref.Name)
+}
@@ -0,0 +1,180 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestTableAccess_Allows(t *testing.T) {
+	access, err := newTableAccess([]string{"metrics_*", "Users"}, []string{"*_secret"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		table string
+		want  bool
+	}{
+		{"metrics_cpu", true},
+		{"METRICS_mem", true},
+		{"users", true},
+		{"metrics_secret", false},
+		{"orders", false},
+		{"sqlite_master", false},
+	}
+	for _, tt := range tests {
+		if got := access.allows(tt.table); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.table, got, tt.want)
+		}
+	}
+
+	denyOnly, _ := newTableAccess(nil, []string{"secrets"})
+	if !denyOnly.allows("orders") || denyOnly.allows("secrets") {
+		t.Error("expected a denylist alone to expose all other tables")
+	}
+	if denyOnly.allows("sqlite_master") || denyOnly.allows("pragma_table_info") {
+		t.Error("expected schema objects to be hidden by default")
+	}
+	wildcard, _ := newTableAccess([]string{"*", "SQLite_Master"}, nil)
+	if !wildcard.allows("sqlite_master") || wildcard.allows("sqlite_schema") {
+		t.Error("expected schema objects to be exposed only when listed by name")
+	}
+
+	if access, _ := newTableAccess(nil, nil); access != nil || !access.allows("anything") {
+		t.Error("expected no patterns to expose all tables")
+	}
+	if _, err := newTableAccess([]string{"[a-"}, nil); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestTableAccess_Check(t *testing.T) {
+	access, _ := newTableAccess(nil, []string{"secrets"})
+
+	tests := []struct {
+		name    string
+		sql     string
+		blocked bool
+	}{
+		{"allowed", "SELECT * FROM metrics", false},
+		{"direct", "SELECT * FROM secrets", true},
+		{"subquery", "SELECT * FROM metrics WHERE id IN (SELECT id FROM main.Secrets)", true},
+		{"cte body", "WITH s AS (SELECT * FROM secrets) SELECT * FROM s", true},
+		{"cte named like a denied table", "WITH secrets AS (SELECT * FROM metrics) SELECT * FROM secrets", false},
+		{"literal", "SELECT 'FROM secrets' FROM metrics", false},
+		{"in table", "SELECT * FROM metrics WHERE v IN secrets", true},
+		{"schema table", "SELECT sql FROM sqlite_master", true},
+		{"pragma function", "SELECT * FROM pragma_table_info('secrets')", true},
+		{"pragma statement", "PRAGMA table_info(secrets)", true},
+		{"form feed", "SELECT * FROM\fsecrets", true},
+		{"vertical tab join", "SELECT * FROM metrics JOIN\vsecrets ON 1", true},
+		{"form feed comma", "SELECT * FROM metrics,\fsecrets", true},
+		{"comment separator", "SELECT * FROM/**/secrets", true},
+		{"string literal table", "SELECT * FROM 'secrets'", true},
+		{"unknown separator", "SELECT * FROM\x00secrets", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := access.check(tt.sql)
+			if got := errors.Is(err, errTableAccess); got != tt.blocked {
+				t.Errorf("expected blocked %v, got %v", tt.blocked, err)
+			}
+		})
+	}
+}
+
+func TestTableAccess_CheckAllowlist(t *testing.T) {
+	access, _ := newTableAccess([]string{"metrics"}, nil)
+
+	tests := []struct {
+		name    string
+		sql     string
+		blocked bool
+	}{
+		{"allowed", "SELECT * FROM\fmetrics", false},
+		{"form feed", "SELECT * FROM metrics,\fsecrets", true},
+		{"vertical tab join", "SELECT * FROM metrics JOIN\vsecrets ON 1", true},
+		{"string literal table", "SELECT * FROM 'metrics'", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := access.check(tt.sql)
+			if got := errors.Is(err, errTableAccess); got != tt.blocked {
+				t.Errorf("expected blocked %v, got %v", tt.blocked, err)
+			}
+		})
+	}
+}
+
+func TestTableAccess_Resources(t *testing.T) {
+	var statements []string
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		sql := decodeQueries(t, r)[0]
+		statements = append(statements, sql)
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{schemaResult("metrics", "secrets")}})
+	})
+	defer server.Close()
+	ds.tables, _ = newTableAccess(nil, []string{"secrets"})
+
+	rec := httptest.NewRecorder()
+	ds.handleTables(rec, httptest.NewRequest(http.MethodGet, "/tables", nil))
+	var tables []string
+	_ = json.NewDecoder(rec.Body).Decode(&tables)
+	if len(tables) != 1 || tables[0] != "metrics" {
+		t.Errorf("expected only metrics to be listed, got %v", tables)
+	}
+
+	for url, handler := range map[string]http.HandlerFunc{
+		"/columns?table=secrets":         ds.handleColumns,
+		"/values?table=secrets&column=v": ds.handleValues,
+	} {
+		statements = nil
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", url, rec.Code)
+		}
+		if len(statements) != 0 {
+			t.Errorf("%s: expected no rqlite request, got %q", url, statements)
+		}
+	}
+
+	body, _ := json.Marshal(resourceQueryRequest{Query: QueryModel{RawSQL: "SELECT *\nFROM metrics JOIN secrets USING (id)"}})
+	rec = httptest.NewRecorder()
+	ds.handleValidate(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	var resp ValidateResponse
+	_ = json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.Valid || len(resp.Errors) != 1 || resp.Errors[0].Line != 2 || resp.Errors[0].Column != 19 {
+		t.Errorf("expected an error at the denied table, got %+v", resp)
+	}
+}
+
+func TestTableAccess_Query(t *testing.T) {
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected rqlite request")
+	})
+	defer server.Close()
+	ds.tables, _ = newTableAccess([]string{"metrics"}, nil)
+
+	qmJSON, _ := json.Marshal(QueryModel{RawSQL: "WITH m AS (SELECT * FROM metrics) SELECT * FROM m JOIN secrets", Format: "table"})
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: qmJSON}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res := resp.Responses["A"]
+	if res.Status != backend.StatusForbidden {
+		t.Fatalf("expected status 403, got %v: %v", res.Status, res.Error)
+	}
+	if want := `table "secrets": access denied by the data source's table settings`; res.Error.Error() != want {
+		t.Errorf("got error %q, want %q", res.Error, want)
+	}
+}
@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+var tenantFilterRegex = regexp.MustCompile(`\$__tenantFilter\((\w+)\)`)
+
+// errNoTenant is returned for Grafana users without a tenant while a tenant
+// policy is configured.
+var errNoTenant = errors.New("no tenant is mapped to this Grafana user")
+
+// TenantPolicy maps Grafana users to the tenant whose rows they may read. A
+// user's login is looked up first, then their org ID. Grafana does not pass
+// team memberships to plugins, so teams cannot be mapped.
+type TenantPolicy struct {
+	Users map[string]string `json:"users"` // tenant by Grafana login
+	Orgs  map[string]string `json:"orgs"`  // tenant by org ID
+
+	// Tables maps protected tables to their tenant column. Reads of a
+	// protected table only see the rows of the user's tenant.
+	Tables map[string]string `json:"tables"`
+}
+
+// resolve returns the tenant of the user of a request.
+func (p *TenantPolicy) resolve(pCtx backend.PluginContext) (string, error) {
+	if pCtx.User != nil {
+		if tenant, ok := p.Users[pCtx.User.Login]; ok {
+			return tenant, nil
+		}
+	}
+	if tenant, ok := p.Orgs[strconv.FormatInt(pCtx.OrgID, 10)]; ok {
+		return tenant, nil
+	}
+	return "", errNoTenant
+}
+
+// tenantScope is the tenant of a query and the tenant column of each
+// protected table, keyed by lowercase table name.
+type tenantScope struct {
+	Tenant string
+	Tables map[string]string
+}
+
+// withTenant returns a context whose queries only read the rows of the
+// tenant of the request's user. The context is returned unchanged if no
+// tenant policy is configured.
+func (d *Datasource) withTenant(ctx context.Context, pCtx backend.PluginContext) (context.Context, error) {
+	policy := d.settings.TenantPolicy
+	if policy == nil {
+		return ctx, nil
+	}
+	tenant, err := policy.resolve(pCtx)
+	if err != nil {
+		return nil, err
+	}
+	scope := &tenantScope{Tenant: tenant, Tables: make(map[string]string, len(policy.Tables))}
+	for table, column := range policy.Tables {
+		scope.Tables[strings.ToLower(table)] = column
+	}
+	return withTenantScope(ctx, scope), nil
+}
+
+type tenantKey struct{}
+
+func withTenantScope(ctx context.Context, scope *tenantScope) context.Context {
+	return context.WithValue(ctx, tenantKey{}, scope)
+}
+
+func tenantFromContext(ctx context.Context) *tenantScope {
+	scope, _ := ctx.Value(tenantKey{}).(*tenantScope)
+	return scope
+}
+
+// tenantName returns the tenant of the context, or "" without a tenant
+// policy. Caches and streams are kept apart by it.
+func tenantName(ctx context.Context) string {
+	if scope := tenantFromContext(ctx); scope != nil {
+		return scope.Tenant
+	}
+	return ""
+}
+
+// applyTenantFilter replaces $__tenantFilter(col) with a condition matching
+// the rows of the context's tenant.
+func applyTenantFilter(ctx context.Context, sql string) (string, error) {
+	if !tenantFilterRegex.MatchString(sql) {
+		return sql, nil
+	}
+	scope := tenantFromContext(ctx)
+	if scope == nil {
+		return "", errors.New("$__tenantFilter needs a tenant policy in the data source settings")
+	}
+	return tenantFilterRegex.ReplaceAllStringFunc(sql, func(match string) string {
+		col := tenantFilterRegex.FindStringSubmatch(match)[1]
+		return fmt.Sprintf("%s = %s", quoteIdentifier(col), quoteLiteral(scope.Tenant))
+	}), nil
+}
+
+// restrictTenant rewrites each reference to a protected table into a
+// subquery of the tenant's rows, keeping the table name as alias except on
+// the right of IN, so that no condition of the statement can widen the rows
+// read. Statements are returned unchanged without a tenant policy, and
+// statements with a table reference that cannot be read are rejected.
+func restrictTenant(ctx context.Context, sql string) (string, error) {
+	scope := tenantFromContext(ctx)
+	if scope == nil || len(scope.Tables) == 0 {
+		return sql, nil
+	}
+
+	refs := tableRefs(sql)
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Start > refs[j].Start })
+	for _, ref := range refs {
+		if ref.Unresolved {
+			return "", fmt.Errorf("cannot restrict the query to the tenant: unrecognized table reference %q", ref.Name)
+		}
+		column, ok := scope.Tables[strings.ToLower(ref.Name)]
+		if !ok {
+			continue
+		}
+		replacement := fmt.Sprintf("(SELECT * FROM %s WHERE %s = %s)", sql[ref.Start:ref.End], quoteIdentifier(column), quoteLiteral(scope.Tenant))
+		if !ref.Aliased && !ref.Operand {
+			replacement += " AS " + quoteIdentifier(ref.Name)
+		}
+		sql = sql[:ref.Start] + replacement + sql[ref.End:]
+	}
+	return sql, nil
+}
@@ -0,0 +1,179 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestTenantPolicy_Resolve(t *testing.T) {
+	policy := &TenantPolicy{
+		Users: map[string]string{"alice": "blue"},
+		Orgs:  map[string]string{"2": "green"},
+	}
+
+	tests := []struct {
+		name string
+		pCtx backend.PluginContext
+		want string
+		err  error
+	}{
+		{"login", backend.PluginContext{OrgID: 2, User: &backend.User{Login: "alice"}}, "blue", nil},
+		{"org", backend.PluginContext{OrgID: 2, User: &backend.User{Login: "bob"}}, "green", nil},
+		{"org without user", backend.PluginContext{OrgID: 2}, "green", nil},
+		{"unmapped", backend.PluginContext{OrgID: 3, User: &backend.User{Login: "bob"}}, "", errNoTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := policy.resolve(tt.pCtx)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyTenantFilter(t *testing.T) {
+	ctx := withTenantScope(context.Background(), &tenantScope{Tenant: "o'brien"})
+	got, err := applyTenantFilter(ctx, "SELECT * FROM t WHERE $__tenantFilter(team) AND x = 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `SELECT * FROM t WHERE "team" = 'o''brien' AND x = 1`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := applyTenantFilter(context.Background(), "SELECT * FROM t WHERE $__tenantFilter(team)"); err == nil {
+		t.Error("expected an error without a tenant policy")
+	}
+}
+
+func TestRestrictTenant(t *testing.T) {
+	ctx := withTenantScope(context.Background(), &tenantScope{
+		Tenant: "blue",
+		Tables: map[string]string{"events": "team", "my table": "tenant"},
+	})
+
+	tests := []struct {
+		name, sql, want string
+	}{
+		{
+			"unaliased",
+			"SELECT * FROM events WHERE 1=1 OR 2=2",
+			`SELECT * FROM (SELECT * FROM events WHERE "team" = 'blue') AS "events" WHERE 1=1 OR 2=2`,
+		},
+		{
+			"aliased and joined",
+			`SELECT * FROM main."My Table" m JOIN users u ON u.id = m.uid`,
+			`SELECT * FROM (SELECT * FROM main."My Table" WHERE "tenant" = 'blue') m JOIN users u ON u.id = m.uid`,
+		},
+		{
+			"subquery and cte",
+			"WITH e AS (SELECT * FROM Events) SELECT * FROM e WHERE id IN (SELECT id FROM events AS x)",
+			`WITH e AS (SELECT * FROM (SELECT * FROM Events WHERE "team" = 'blue') AS "Events") SELECT * FROM e WHERE id IN (SELECT id FROM (SELECT * FROM events WHERE "team" = 'blue') AS x)`,
+		},
+		{
+			"in table",
+			"SELECT 'acme' IN events, 'x' IN main.events",
+			`SELECT 'acme' IN (SELECT * FROM events WHERE "team" = 'blue'), 'x' IN (SELECT * FROM main.events WHERE "team" = 'blue')`,
+		},
+		{
+			"unprotected",
+			"SELECT * FROM users",
+			"SELECT * FROM users",
+		},
+		{
+			"form feed",
+			"SELECT * FROM\fevents",
+			"SELECT * FROM\f(SELECT * FROM events WHERE \"team\" = 'blue') AS \"events\"",
+		},
+		{
+			"vertical tab join",
+			"SELECT * FROM x JOIN\vevents ON 1",
+			"SELECT * FROM x JOIN\v(SELECT * FROM events WHERE \"team\" = 'blue') AS \"events\" ON 1",
+		},
+		{
+			"distinct from",
+			"SELECT * FROM events WHERE a IS NOT DISTINCT FROM b",
+			`SELECT * FROM (SELECT * FROM events WHERE "team" = 'blue') AS "events" WHERE a IS NOT DISTINCT FROM b`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := restrictTenant(ctx, tt.sql)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+
+	// SQLite takes a string literal after FROM for a table name, and other
+	// separators may follow in the future, so unreadable references fail.
+	for _, sql := range []string{
+		"SELECT * FROM 'events'",
+		"SELECT * FROM x, 'events'",
+		"SELECT * FROM x JOIN\x00events ON 1",
+	} {
+		if got, err := restrictTenant(ctx, sql); err == nil {
+			t.Errorf("expected %q to be rejected, got %q", sql, got)
+		}
+	}
+
+	sql := "SELECT * FROM 'events'"
+	if got, err := restrictTenant(context.Background(), sql); err != nil || got != sql {
+		t.Errorf("expected statements to be unchanged without a tenant policy, got %q, %v", got, err)
+	}
+}
+
+func TestTenantPolicy_Query(t *testing.T) {
+	var statements []string
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		var req []string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		statements = append(statements, req[0])
+		result := RqliteResult{Columns: []string{"v"}, Types: []string{"integer"}, Values: [][]interface{}{{1.0}}}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+	ds.settings.TenantPolicy = &TenantPolicy{
+		Users:  map[string]string{"alice": "blue"},
+		Tables: map[string]string{"events": "team"},
+	}
+
+	queryAs := func(login, sql string) backend.DataResponse {
+		qmJSON, _ := json.Marshal(QueryModel{RawSQL: sql, Format: "table"})
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			PluginContext: backend.PluginContext{User: &backend.User{Login: login}},
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: qmJSON}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return resp.Responses["A"]
+	}
+
+	if res := queryAs("alice", "SELECT v FROM events JOIN labels ON $__tenantFilter(owner)"); res.Error != nil {
+		t.Fatalf("unexpected response error: %v", res.Error)
+	}
+	want := `SELECT v FROM (SELECT * FROM events WHERE "team" = 'blue') AS "events" JOIN labels ON "owner" = 'blue'`
+	if len(statements) != 1 || statements[0] != want {
+		t.Errorf("got statements %q, want %q", statements, want)
+	}
+
+	if res := queryAs("mallory", "SELECT v FROM events"); res.Status != backend.StatusForbidden {
+		t.Errorf("expected users without a tenant to be forbidden, got status %v", res.Status)
+	}
+	if len(statements) != 1 {
+		t.Errorf("expected no request for users without a tenant, got %q", statements)
+	}
+}
@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// timeSampleSize is the number of non-null values checked before a column is
+// taken to hold times.
+const timeSampleSize = 20
+
+// minEpochSeconds is the smallest number taken for a Unix time in columns
+// named like times, in March 1973, so that counts and IDs are not.
+const minEpochSeconds = 1e8
+
+// timeColumnNames are column names and aliases that usually hold times.
+var timeColumnNames = map[string]bool{
+	"time":      true,
+	"timestamp": true,
+	"ts":        true,
+	"datetime":  true,
+	"date":      true,
+	"time_sec":  true,
+	"created":   true,
+	"updated":   true,
+}
+
+var timeColumnSuffixes = []string{"_at", "_time", "_ts", "_timestamp", "_date"}
+
+// detectTimeFields replaces the fields of a frame that hold times with time
+// fields. A column holds times if it is declared DATETIME, TIMESTAMP or DATE,
+// if it is named like a time and its values are Unix times or time strings,
+// or if it is a text column whose values are all time strings. Only the
+// first values of each column are sampled, so later values may not be times;
+// they become null, with a notice on the frame.
+func detectTimeFields(frame *data.Frame, types []string) {
+	for i, field := range frame.Fields {
+		if field.Type().Time() || field.Type() == data.FieldTypeNullableBool {
+			continue
+		}
+		colType := ""
+		if i < len(types) {
+			colType = strings.ToLower(types[i])
+		}
+		if !isTimeType(colType) && !holdsTimes(field) {
+			continue
+		}
+
+		times := make([]*time.Time, field.Len())
+		failed := 0
+		for row := range times {
+			v, ok := field.ConcreteAt(row)
+			if !ok {
+				continue
+			}
+			if t, ok := defaultTimeParser.parse(v); ok {
+				times[row] = &t
+			} else {
+				failed++
+			}
+		}
+		if failed > 0 {
+			appendTimeNotice(frame, field.Name, failed)
+		}
+		detected := data.NewField(field.Name, field.Labels, times)
+		detected.Config = field.Config
+		frame.Fields[i] = detected
+	}
+}
+
+// isTimeType reports whether a declared column type is a time type. SQLite
+// has no such types, but keeps the declared name.
+func isTimeType(colType string) bool {
+	return strings.Contains(colType, "datetime") || strings.Contains(colType, "timestamp") || colType == "date"
+}
+
+// isTimeName reports whether a column name or alias is usual for times.
+func isTimeName(name string) bool {
+	name = strings.ToLower(name)
+	if timeColumnNames[name] {
+		return true
+	}
+	for _, suffix := range timeColumnSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsTimes samples the values of a field: columns named like times may
+// hold Unix times or time strings, other text columns only time strings.
+func holdsTimes(field *data.Field) bool {
+	named := isTimeName(field.Name)
+	if !named && field.Type() != data.FieldTypeNullableString {
+		return false
+	}
+
+	seen := 0
+	for row := 0; row < field.Len() && seen < timeSampleSize; row++ {
+		v, ok := field.ConcreteAt(row)
+		if !ok {
+			continue
+		}
+		seen++
+		if s, ok := v.(string); ok {
+			if _, ok := parseTimeString(s); ok {
+				continue
+			}
+		}
+		if !named || !isEpoch(v) {
+			return false
+		}
+	}
+	return seen > 0
+}
+
+// isEpoch reports whether a value is a plausible Unix time in seconds or a
+// finer unit, as numbers or, for untyped columns, as number strings.
+func isEpoch(v interface{}) bool {
+	f, ok := epochValue(v)
+	return ok && (f >= minEpochSeconds || f <= -minEpochSeconds)
+}
+
+func epochValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// timeFieldColumns returns the time fields of a frame as time columns.
+func timeFieldColumns(frame *data.Frame) []TimeColumn {
+	var cols []TimeColumn
+	for _, field := range frame.Fields {
+		if field.Type().Time() {
+			cols = append(cols, TimeColumn{Name: field.Name})
+		}
+	}
+	return cols
+}
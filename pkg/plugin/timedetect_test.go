@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestResultToFrame_DetectTimeColumns(t *testing.T) {
+	newResult := func() *RqliteResult {
+		return &RqliteResult{
+			Columns: []string{"logged", "created_at", "bucket", "label", "id", "day", "count_ts", "note"},
+			Types:   []string{"DATETIME", "integer", "", "text", "integer", "text", "integer", "text"},
+			Values: [][]interface{}{
+				{"2024-01-02 03:04:05", float64(1704164645), float64(1704164645000), "2024-01-02T03:04:05Z", float64(1704164645), "2024-01-02", float64(3), "2024-01-02"},
+				{nil, nil, float64(1704164705000), nil, float64(2), "2024-01-03", float64(4), "soon"},
+			},
+		}
+	}
+
+	frame, err := ResultToFrame(newResult(), FrameOptions{DetectTimeColumns: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]bool{
+		"logged":     true,  // declared type
+		"created_at": true,  // name and Unix seconds
+		"bucket":     false, // untyped expression, not named like a time
+		"label":      true,  // ISO strings
+		"id":         false, // not named like a time
+		"day":        true,  // name and date strings
+		"count_ts":   false, // named like a time, but too small for Unix times
+		"note":       false, // not all values are times
+	}
+	for _, field := range frame.Fields {
+		if got := field.Type().Time(); got != want[field.Name] {
+			t.Errorf("%s: got time field %v, want %v", field.Name, got, want[field.Name])
+		}
+	}
+
+	created, _ := frame.Fields[1].ConcreteAt(0)
+	if wantTime := time.Unix(1704164645, 0).UTC(); !created.(time.Time).Equal(wantTime) {
+		t.Errorf("created_at: got %v, want %v", created, wantTime)
+	}
+	if _, ok := frame.Fields[0].ConcreteAt(1); ok {
+		t.Error("expected NULL to stay null")
+	}
+
+	// An explicit list overrides detection.
+	frame, _ = ResultToFrame(newResult(), FrameOptions{DetectTimeColumns: true, TimeColumns: []TimeColumn{{Name: "id"}}})
+	for _, field := range frame.Fields {
+		if got := field.Type().Time(); got != (field.Name == "id") {
+			t.Errorf("%s: got time field %v with explicit time columns", field.Name, got)
+		}
+	}
+
+	frame, _ = ResultToFrame(newResult(), FrameOptions{})
+	if cols := timeFieldColumns(frame); len(cols) != 0 {
+		t.Errorf("expected no detection by default, got %v", cols)
+	}
+}
+
+func TestResultToFrame_DetectTimeGroupAlias(t *testing.T) {
+	// $__timeGroup yields an untyped expression with the alias "time".
+	result := &RqliteResult{
+		Columns: []string{"time", "avg"},
+		Types:   []string{"", ""},
+		Values:  [][]interface{}{{float64(1704164640000), 1.5}},
+	}
+	frame, err := ResultToFrame(result, FrameOptions{DetectTimeColumns: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := timeFieldColumns(frame); len(got) != 1 || got[0].Name != "time" {
+		t.Fatalf("expected time to be detected, got %v", got)
+	}
+	ts, _ := frame.Fields[0].ConcreteAt(0)
+	if want := time.UnixMilli(1704164640000).UTC(); !ts.(time.Time).Equal(want) {
+		t.Errorf("got %v, want %v", ts, want)
+	}
+}
+
+func TestMergeChunks_DetectTimeColumns(t *testing.T) {
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Close()
+
+	chunk := func(ts float64) chunkResult {
+		b := newFrameBuilder([]string{"ts", "value"}, []string{"integer", "real"}, FrameOptions{}, 1)
+		b.appendRow([]interface{}{ts, 1.0})
+		result := RqliteResult{Columns: []string{"ts", "value"}, Types: []string{"integer", "real"}, Frame: b.frame}
+		return chunkResult{resp: &RqliteQueryResponse{Results: []RqliteResult{result}}}
+	}
+
+	merged, err := ds.mergeChunks([]chunkResult{chunk(1704164705), chunk(1704164645)}, FrameOptions{DetectTimeColumns: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frame := merged.Results[0].Frame
+	if frame.Fields[0].Type() != data.FieldTypeNullableTime {
+		t.Fatalf("expected ts to be detected, got %v", frame.Fields[0].Type())
+	}
+	first, _ := frame.Fields[0].ConcreteAt(0)
+	if want := time.Unix(1704164645, 0).UTC(); !first.(time.Time).Equal(want) {
+		t.Errorf("expected the merged rows to be sorted by the detected time, got %v first", first)
+	}
+}
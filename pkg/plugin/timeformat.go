@@ -0,0 +1,202 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Plugin hosts, Windows in particular, may lack a time zone database.
+	_ "time/tzdata"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// TimeColumn is a column parsed as time. In queries it is either the column
+// name or an object with the settings for parsing its values.
+type TimeColumn struct {
+	Name string `json:"name"`
+
+	// Format is a Go layout, such as "02/01/2006 15:04", or a strftime
+	// format, such as "%d/%m/%Y %H:%M", for text values. By default RFC 3339
+	// and SQLite's date and time formats are accepted.
+	Format string `json:"format,omitempty"`
+
+	// Unit is the unit of Unix times: "s", "ms", "us" or "ns". By default it
+	// is told by magnitude.
+	Unit string `json:"unit,omitempty"`
+
+	// Timezone is the IANA time zone of text values without an offset,
+	// defaulting to UTC.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// UnmarshalJSON accepts a column name as well as an object.
+func (c *TimeColumn) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte(`"`)) {
+		*c = TimeColumn{}
+		return json.Unmarshal(b, &c.Name)
+	}
+	type timeColumn TimeColumn
+	return json.Unmarshal(b, (*timeColumn)(c))
+}
+
+// defaultTimeLayouts are the layouts of text values tried without a format.
+// Fractional seconds are accepted after the seconds of any of them.
+var defaultTimeLayouts = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02",
+}
+
+// timeParser parses the values of a time column.
+type timeParser struct {
+	layouts []string
+	unit    string
+	loc     *time.Location
+}
+
+// defaultTimeParser parses values of time columns without settings.
+var defaultTimeParser = &timeParser{layouts: defaultTimeLayouts, loc: time.UTC}
+
+// newTimeParser returns the parser for the settings of a time column.
+func newTimeParser(c TimeColumn) (*timeParser, error) {
+	p := &timeParser{layouts: defaultTimeLayouts, loc: time.UTC}
+	if c.Format != "" {
+		layout, err := timeLayout(c.Format)
+		if err != nil {
+			return nil, err
+		}
+		p.layouts = []string{layout}
+	}
+	switch c.Unit {
+	case "", "s", "ms", "us", "ns":
+		p.unit = c.Unit
+	default:
+		return nil, fmt.Errorf("unknown time unit %q, expected s, ms, us or ns", c.Unit)
+	}
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", c.Timezone)
+		}
+		p.loc = loc
+	}
+	return p, nil
+}
+
+// parse converts a value to a time, reporting false for values that match
+// none of the layouts and are no numbers. Text holding a number is taken for
+// a Unix time.
+func (p *timeParser) parse(val interface{}) (time.Time, bool) {
+	switch v := val.(type) {
+	case float64:
+		return epochToTime(v, p.unit), true
+	case int64:
+		return epochToTime(float64(v), p.unit), true
+	case string:
+		for _, layout := range p.layouts {
+			if t, err := time.ParseInLocation(layout, v, p.loc); err == nil {
+				return t, true
+			}
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return epochToTime(f, p.unit), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseTimeString parses text in RFC 3339 or SQLite's date and time formats.
+func parseTimeString(v string) (time.Time, bool) {
+	for _, layout := range defaultTimeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// epochToTime converts a Unix time in a unit, or in the unit told by its
+// magnitude if none is set.
+func epochToTime(v float64, unit string) time.Time {
+	switch unit {
+	case "s":
+		sec := int64(v)
+		return time.Unix(sec, int64((v-float64(sec))*1e9)).UTC()
+	case "ms":
+		return time.Unix(0, int64(v*1e6)).UTC()
+	case "us":
+		return time.Unix(0, int64(v*1e3)).UTC()
+	case "ns":
+		return time.Unix(0, int64(v)).UTC()
+	default:
+		return unixToTime(v)
+	}
+}
+
+// strftimeLayouts maps strftime directives to Go layout elements.
+var strftimeLayouts = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'j': "002",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'f': "000000",
+	'p': "PM",
+	'b': "Jan",
+	'h': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'z': "-0700",
+	'Z': "MST",
+	'F': "2006-01-02",
+	'T': "15:04:05",
+	'D': "01/02/06",
+	'%': "%",
+}
+
+// timeLayout returns the Go layout of a time format. Formats with a %
+// directive are strftime formats, others are Go layouts already.
+func timeLayout(format string) (string, error) {
+	if !strings.Contains(format, "%") {
+		return format, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i == len(format) {
+			return "", fmt.Errorf("time format %q ends in %%", format)
+		}
+		elem, ok := strftimeLayouts[format[i]]
+		if !ok {
+			return "", fmt.Errorf("unsupported directive %%%c in time format %q", format[i], format)
+		}
+		b.WriteString(elem)
+	}
+	return b.String(), nil
+}
+
+// appendTimeNotice warns on a frame that values of a time column were not
+// times and became null.
+func appendTimeNotice(frame *data.Frame, column string, n int) {
+	frame.AppendNotices(data.Notice{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("%d values of time column %q could not be parsed and are shown as null", n, column),
+	})
+}
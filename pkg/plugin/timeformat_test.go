@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeColumn_UnmarshalJSON(t *testing.T) {
+	var qm QueryModel
+	err := json.Unmarshal([]byte(`{"timeColumns":["time",{"name":"ts","format":"%d/%m/%Y","unit":"ms","timezone":"Europe/Berlin"}]}`), &qm)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []TimeColumn{{Name: "time"}, {Name: "ts", Format: "%d/%m/%Y", Unit: "ms", Timezone: "Europe/Berlin"}}
+	if len(qm.TimeColumns) != len(want) {
+		t.Fatalf("got %+v, want %+v", qm.TimeColumns, want)
+	}
+	for i := range want {
+		if qm.TimeColumns[i] != want[i] {
+			t.Errorf("got %+v, want %+v", qm.TimeColumns[i], want[i])
+		}
+	}
+}
+
+func TestTimeParser(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	tests := []struct {
+		name string
+		col  TimeColumn
+		val  interface{}
+		want time.Time
+	}{
+		{"default milliseconds", TimeColumn{}, "2024-01-02T03:04:05.250", time.Date(2024, 1, 2, 3, 4, 5, 250e6, time.UTC)},
+		{"default offset", TimeColumn{}, "2024-01-02 03:04:05+02:00", time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC)},
+		{"number text", TimeColumn{}, "1704164645", time.Unix(1704164645, 0)},
+		{"go layout", TimeColumn{Format: "02/01/2006 15:04"}, "02/01/2024 03:04", time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)},
+		{"strftime", TimeColumn{Format: "%d.%m.%Y %H:%M:%S"}, "02.01.2024 03:04:05", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"strftime fraction", TimeColumn{Format: "%F %T.%f"}, "2024-01-02 03:04:05.000123", time.Date(2024, 1, 2, 3, 4, 5, 123000, time.UTC)},
+		{"timezone", TimeColumn{Timezone: "Europe/Berlin"}, "2024-01-02 03:04:05", time.Date(2024, 1, 2, 3, 4, 5, 0, berlin)},
+		{"timezone with offset", TimeColumn{Timezone: "Europe/Berlin"}, "2024-01-02T03:04:05Z", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"unit ms", TimeColumn{Unit: "ms"}, float64(86400000), time.Unix(86400, 0)},
+		{"unit s", TimeColumn{Unit: "s"}, float64(1704164645000), time.Unix(1704164645000, 0)},
+		{"unit us", TimeColumn{Unit: "us"}, "1704164645000000", time.Unix(1704164645, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newTimeParser(tt.col)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, ok := p.parse(tt.val)
+			if !ok {
+				t.Fatalf("expected %v to parse", tt.val)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := defaultTimeParser.parse("02/01/2024"); ok {
+		t.Error("expected text in other formats not to parse without a format")
+	}
+}
+
+func TestFrameOptions_ValidateTimeColumns(t *testing.T) {
+	for _, col := range []TimeColumn{
+		{Name: "ts", Unit: "minutes"},
+		{Name: "ts", Timezone: "Mars/Olympus"},
+		{Name: "ts", Format: "%Y-%Q"},
+		{Name: "ts", Format: "%Y%"},
+	} {
+		if err := (FrameOptions{TimeColumns: []TimeColumn{col}}).validate(); err == nil {
+			t.Errorf("expected an error for %+v", col)
+		}
+	}
+	if err := (FrameOptions{TimeColumns: []TimeColumn{{Name: "ts", Format: "%Y-%m-%d", Unit: "ms", Timezone: "UTC"}}}).validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResultToFrame_UnparseableTimes(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"ts", "value"},
+		Types:   []string{"text", "integer"},
+		Values: [][]interface{}{
+			{"2024-01-02 03:04:05", float64(1)},
+			{"yesterday", float64(2)},
+			{nil, float64(3)},
+			{"n/a", float64(4)},
+		},
+	}
+
+	frame, err := ResultToFrame(result, FrameOptions{TimeColumns: []TimeColumn{{Name: "ts"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for row, wantOK := range []bool{true, false, false, false} {
+		if _, ok := frame.Fields[0].ConcreteAt(row); ok != wantOK {
+			t.Errorf("row %d: got a time %v, want %v", row, ok, wantOK)
+		}
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 {
+		t.Fatalf("expected one notice, got %+v", frame.Meta)
+	}
+	if text := frame.Meta.Notices[0].Text; !strings.HasPrefix(text, `2 values of time column "ts"`) {
+		t.Errorf("unexpected notice %q", text)
+	}
+
+	// Notices are kept when converting to time series.
+	ts, err := ToTimeSeriesFrame(frame)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ts.Meta.Notices) != 1 {
+		t.Errorf("expected the notice to be kept, got %+v", ts.Meta.Notices)
+	}
+}
+
+func TestMergeChunks_KeepsTimeNotices(t *testing.T) {
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Close()
+
+	chunk := func(ts string) chunkResult {
+		b := newFrameBuilder([]string{"ts"}, []string{"text"}, FrameOptions{TimeColumns: []TimeColumn{{Name: "ts"}}}, 2)
+		b.appendRow([]interface{}{ts})
+		b.appendRow([]interface{}{"later"})
+		b.finish()
+		result := RqliteResult{Columns: []string{"ts"}, Types: []string{"text"}, Frame: b.frame}
+		return chunkResult{resp: &RqliteQueryResponse{Results: []RqliteResult{result}}}
+	}
+
+	merged, err := ds.mergeChunks([]chunkResult{chunk("2024-01-03"), chunk("2024-01-02")}, FrameOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frame := merged.Results[0].Frame
+	if frame.Meta == nil || len(frame.Meta.Notices) != 2 {
+		t.Errorf("expected the notices of both chunks, got %+v", frame.Meta)
+	}
+}
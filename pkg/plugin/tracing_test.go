@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryData_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevTracer, prevPropagator := tracing.DefaultTracer(), otel.GetTextMapPropagator()
+	tracing.InitDefaultTracer(provider.Tracer("test"))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		tracing.InitDefaultTracer(prevTracer)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	var traceparent string
+	ds, server := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		result := RqliteResult{Columns: []string{"v"}, Types: []string{"integer"}, Values: [][]interface{}{{1.0}, {2.0}}}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{result}})
+	})
+	defer server.Close()
+
+	qmJSON, _ := json.Marshal(QueryModel{RawSQL: "SELECT v FROM t WHERE host = 'secret'", Format: "table"})
+	_, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: qmJSON}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+		for _, attr := range span.Attributes() {
+			if attr.Value.Emit() == "SELECT v FROM t WHERE host = 'secret'" {
+				t.Errorf("span %s records the raw SQL", span.Name())
+			}
+		}
+	}
+	for _, name := range []string{"Datasource.query", "expandQuery", "RqliteClient.QueryFrame", "Datasource.convertFrames"} {
+		if _, ok := spans[name]; !ok {
+			t.Errorf("missing span %s", name)
+		}
+	}
+
+	query := spans["Datasource.query"]
+	client := spans["RqliteClient.QueryFrame"]
+	if client.Parent().SpanID() != query.SpanContext().SpanID() {
+		t.Error("expected the client span to be a child of the query span")
+	}
+	attrs := map[string]string{}
+	for _, attr := range client.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs["rqlite.consistency_level"] != "weak" || attrs["rqlite.rows"] != "2" ||
+		attrs["rqlite.statement_fingerprint"] != sqlFingerprint("SELECT v FROM t WHERE host = 'secret'") {
+		t.Errorf("unexpected client span attributes: %v", attrs)
+	}
+	if traceparent == "" || traceparent[3:35] != client.SpanContext().TraceID().String() {
+		t.Errorf("expected the trace context to be propagated, got %q", traceparent)
+	}
+}
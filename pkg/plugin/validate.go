@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+var (
+	// SQLite syntax errors name the offending token, e.g. `near "FORM": syntax error`.
+	sqlErrorNearRegex = regexp.MustCompile(`near "((?:[^"]|"")*)"`)
+	// Schema errors name the missing object, e.g. `no such column: h.name`.
+	sqlErrorNoSuchRegex = regexp.MustCompile(`no such (?:table|column|function): (\S+)`)
+)
+
+// SQLError is a positioned error in a query. Lines and columns are 1-based;
+// they are zero if the error could not be located in the query.
+type SQLError struct {
+	Source    string `json:"source"` // "macro" or "sql"
+	Message   string `json:"message"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+}
+
+// ValidateResponse is the response body of the /validate endpoint.
+type ValidateResponse struct {
+	Valid  bool       `json:"valid"`
+	SQL    string     `json:"sql"`
+	Errors []SQLError `json:"errors"`
+}
+
+func (d *Datasource) handleValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req resourceQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Query.RawSQL == "" {
+		http.Error(w, "query is empty", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := d.validate(r.Context(), req)
+	if err != nil {
+		log.DefaultLogger.Error("Failed to validate query", "error", err)
+		http.Error(w, genericQueryErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// validate checks the query's macros, then prepares the expanded statement
+// with EXPLAIN, which compiles it against the schema without running it.
+func (d *Datasource) validate(ctx context.Context, req resourceQueryRequest) (*ValidateResponse, error) {
+	rawSQL := req.Query.RawSQL
+	resp := &ValidateResponse{Errors: ValidateMacros(rawSQL)}
+	if resp.Errors == nil {
+		resp.Errors = []SQLError{}
+	}
+
+	if ref, ok := d.tables.blockedRef(rawSQL); ok {
+		resp.Errors = append(resp.Errors, newSQLError(rawSQL, ref.Start, ref.End, "sql", refAccessError(ref).Error()))
+		return resp, nil
+	}
+
+	sql, args, err := d.expandQuery(ctx, &req.Query, req.timeRange(), req.IntervalMS)
+	if err != nil {
+		resp.Errors = append(resp.Errors, SQLError{Source: "macro", Message: err.Error()})
+		return resp, nil
+	}
+	resp.SQL = sql
+
+	result, err := d.client.Query(ctx, "EXPLAIN "+sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Results) > 0 && result.Results[0].Error != "" {
+		resp.Errors = append(resp.Errors, locateSQLError(rawSQL, result.Results[0].Error))
+	}
+
+	resp.Valid = len(resp.Errors) == 0
+	return resp, nil
+}
+
+// locateSQLError positions a SQLite error message in the original query by
+// searching for the token or object it names. Since the statement was
+// macro-expanded, the first occurrence in the original query is used.
+func locateSQLError(sql, message string) SQLError {
+	sqlErr := SQLError{Source: "sql", Message: message}
+
+	var token string
+	if sub := sqlErrorNearRegex.FindStringSubmatch(message); sub != nil {
+		token = strings.ReplaceAll(sub[1], `""`, `"`)
+	} else if sub := sqlErrorNoSuchRegex.FindStringSubmatch(message); sub != nil {
+		token = sub[1]
+		if idx := strings.LastIndex(token, "."); idx >= 0 && !strings.Contains(sql, token) {
+			token = token[idx+1:]
+		}
+	}
+
+	if token == "" {
+		return sqlErr
+	}
+	if idx := strings.Index(sql, token); idx >= 0 {
+		return newSQLError(sql, idx, idx+len(token), sqlErr.Source, sqlErr.Message)
+	}
+	return sqlErr
+}
+
+// newSQLError creates an error spanning the byte offsets start to end of sql.
+func newSQLError(sql string, start, end int, source, message string) SQLError {
+	line, column := position(sql, start)
+	endLine, endColumn := position(sql, end)
+	return SQLError{
+		Source:    source,
+		Message:   message,
+		Line:      line,
+		Column:    column,
+		EndLine:   endLine,
+		EndColumn: endColumn,
+	}
+}
+
+// position converts a byte offset in sql to a 1-based line and rune column.
+func position(sql string, offset int) (int, int) {
+	before := sql[:offset]
+	line := strings.Count(before, "\n") + 1
+	lineStart := strings.LastIndex(before, "\n") + 1
+	return line, utf8.RuneCountInString(before[lineStart:]) + 1
+}
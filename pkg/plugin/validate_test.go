@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocateSQLError(t *testing.T) {
+	sql := "SELECT time, value\nFORM metrics\nWHERE $__timeFilter(time)"
+
+	tests := []struct {
+		name           string
+		message        string
+		expectedLine   int
+		expectedColumn int
+		expectedEnd    int
+	}{
+		{"near token", `near "FORM": syntax error`, 2, 1, 5},
+		{"no such column", `no such column: value`, 1, 14, 19},
+		{"qualified column", `no such column: m.metrics`, 2, 6, 13},
+		{"unlocated", `incomplete input`, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := locateSQLError(sql, tt.message)
+			if err.Message != tt.message || err.Source != "sql" {
+				t.Errorf("unexpected error: %+v", err)
+			}
+			if err.Line != tt.expectedLine || err.Column != tt.expectedColumn || err.EndColumn != tt.expectedEnd {
+				t.Errorf("expected %d:%d-%d, got %d:%d-%d", tt.expectedLine, tt.expectedColumn, tt.expectedEnd, err.Line, err.Column, err.EndColumn)
+			}
+		})
+	}
+}
+
+func TestPosition_CountsRunes(t *testing.T) {
+	line, column := position("SELECT 'äöü', x", len("SELECT 'äöü', "))
+	if line != 1 || column != 15 {
+		t.Errorf("expected 1:15, got %d:%d", line, column)
+	}
+}
+
+func TestHandleValidate(t *testing.T) {
+	ds, rqliteServer := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		sql := decodeQueries(t, r)[0]
+		if !strings.HasPrefix(sql, "EXPLAIN SELECT") {
+			t.Fatalf("expected EXPLAIN statement, got %q", sql)
+		}
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{{Error: `no such column: valu`}}})
+	})
+	defer rqliteServer.Close()
+
+	body, _ := json.Marshal(resourceQueryRequest{
+		Query: QueryModel{RawSQL: "SELECT valu FROM metrics\nWHERE $__timeFilter(time) AND $__timeGroup(time, often) > 0 AND $__bogus"},
+		From:  1000000,
+		To:    2000000,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	ds.handleValidate(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp ValidateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.Valid {
+		t.Fatal("expected invalid query")
+	}
+	if len(resp.Errors) != 3 {
+		t.Fatalf("expected 3 errors, got %+v", resp.Errors)
+	}
+
+	expected := []struct {
+		source string
+		line   int
+		column int
+	}{
+		{"macro", 2, 31},
+		{"macro", 2, 65},
+		{"sql", 1, 8},
+	}
+	for i, e := range expected {
+		got := resp.Errors[i]
+		if got.Source != e.source || got.Line != e.line || got.Column != e.column {
+			t.Errorf("error %d: expected %s at %d:%d, got %+v", i, e.source, e.line, e.column, got)
+		}
+	}
+}
+
+func TestHandleValidate_Valid(t *testing.T) {
+	ds, rqliteServer := setupTestDatasource(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(RqliteQueryResponse{Results: []RqliteResult{{
+			Columns: []string{"addr", "opcode"},
+			Values:  [][]interface{}{{float64(0), "Init"}},
+		}}})
+	})
+	defer rqliteServer.Close()
+
+	body, _ := json.Marshal(resourceQueryRequest{Query: QueryModel{RawSQL: "SELECT 1"}})
+	req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	ds.handleValidate(rec, req)
+
+	var resp ValidateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Valid || len(resp.Errors) != 0 {
+		t.Errorf("expected valid query, got %+v", resp)
+	}
+	if resp.SQL != "SELECT 1" {
+		t.Errorf("unexpected expanded SQL %q", resp.SQL)
+	}
+}
@@ -0,0 +1,120 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultValuesLimit = 100
+	maxValuesLimit     = 1000
+)
+
+// valuesQuery describes a bounded lookup of the distinct values of a column.
+type valuesQuery struct {
+	Table  string
+	Column string
+	Prefix string
+	Limit  int
+
+	// TimeColumn, From and To optionally restrict the lookup to rows whose
+	// epoch-seconds time column falls within the dashboard time range.
+	TimeColumn string
+	From       time.Time
+	To         time.Time
+}
+
+// buildValuesSQL generates the parameterized SQL for a distinct values lookup.
+// Identifiers must already be validated against the schema.
+func buildValuesSQL(q valuesQuery) (string, []interface{}) {
+	column := quoteIdentifier(q.Column)
+
+	conditions := []string{column + " IS NOT NULL"}
+	var args []interface{}
+
+	if q.Prefix != "" {
+		conditions = append(conditions, column+` LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(q.Prefix)+"%")
+	}
+
+	if q.TimeColumn != "" && !q.From.IsZero() && !q.To.IsZero() {
+		timeColumn := quoteIdentifier(q.TimeColumn)
+		conditions = append(conditions, timeColumn+" >= ? AND "+timeColumn+" <= ?")
+		args = append(args, q.From.Unix(), q.To.Unix())
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultValuesLimit
+	}
+	limit = min(limit, maxValuesLimit)
+
+	sql := fmt.Sprintf("SELECT DISTINCT %s FROM %s WHERE %s ORDER BY %s LIMIT %d",
+		column, quoteIdentifier(q.Table), strings.Join(conditions, " AND "), column, limit)
+
+	return sql, args
+}
+
+// distinctValues validates q against the schema and returns the distinct
+// values of its column formatted as strings.
+func (d *Datasource) distinctValues(ctx context.Context, q valuesQuery) ([]string, error) {
+	columns, err := d.tableColumns(ctx, q.Table)
+	if err != nil {
+		return nil, err
+	}
+	if !hasColumn(columns, q.Column) {
+		return nil, fmt.Errorf("%w: %s", errUnknownColumn, q.Column)
+	}
+	if q.TimeColumn != "" && !hasColumn(columns, q.TimeColumn) {
+		return nil, fmt.Errorf("%w: %s", errUnknownColumn, q.TimeColumn)
+	}
+
+	return d.queryValues(ctx, q)
+}
+
+// queryValues runs the distinct values lookup for q without validating it.
+func (d *Datasource) queryValues(ctx context.Context, q valuesQuery) ([]string, error) {
+	sql, args := buildValuesSQL(q)
+	result, err := d.queryResult(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(result.Values))
+	for _, row := range result.Values {
+		if len(row) > 0 && row[0] != nil {
+			values = append(values, formatValue(row[0]))
+		}
+	}
+
+	return values, nil
+}
+
+func hasColumn(columns []ColumnInfo, name string) bool {
+	for _, col := range columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeLike escapes the LIKE wildcards in s using backslash as escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// formatValue renders a decoded rqlite value without exponent notation for numbers.
+func formatValue(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildValuesSQL(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        valuesQuery
+		expectedSQL  string
+		expectedArgs []interface{}
+	}{
+		{
+			name:        "defaults",
+			query:       valuesQuery{Table: "hosts", Column: "name"},
+			expectedSQL: `SELECT DISTINCT "name" FROM "hosts" WHERE "name" IS NOT NULL ORDER BY "name" LIMIT 100`,
+		},
+		{
+			name:         "prefix with wildcards",
+			query:        valuesQuery{Table: "hosts", Column: "name", Prefix: `web_1%`, Limit: 10},
+			expectedSQL:  `SELECT DISTINCT "name" FROM "hosts" WHERE "name" IS NOT NULL AND "name" LIKE ? ESCAPE '\' ORDER BY "name" LIMIT 10`,
+			expectedArgs: []interface{}{`web\_1\%%`},
+		},
+		{
+			name: "time range",
+			query: valuesQuery{
+				Table:      "http-requests",
+				Column:     `odd"col`,
+				TimeColumn: "ts",
+				From:       time.Unix(1000, 0),
+				To:         time.Unix(2000, 0),
+			},
+			expectedSQL:  `SELECT DISTINCT "odd""col" FROM "http-requests" WHERE "odd""col" IS NOT NULL AND "ts" >= ? AND "ts" <= ? ORDER BY "odd""col" LIMIT 100`,
+			expectedArgs: []interface{}{int64(1000), int64(2000)},
+		},
+		{
+			name:        "limit capped",
+			query:       valuesQuery{Table: "hosts", Column: "name", Limit: 1_000_000},
+			expectedSQL: `SELECT DISTINCT "name" FROM "hosts" WHERE "name" IS NOT NULL ORDER BY "name" LIMIT 1000`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := buildValuesSQL(tt.query)
+			if sql != tt.expectedSQL {
+				t.Errorf("expected %q, got %q", tt.expectedSQL, sql)
+			}
+			if !reflect.DeepEqual(args, tt.expectedArgs) {
+				t.Errorf("expected args %v, got %v", tt.expectedArgs, args)
+			}
+		})
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		val      interface{}
+		expected string
+	}{
+		{"web-1", "web-1"},
+		{float64(1000000), "1000000"},
+		{float64(1.5), "1.5"},
+		{true, "true"},
+	}
+
+	for _, tt := range tests {
+		if got := formatValue(tt.val); got != tt.expected {
+			t.Errorf("formatValue(%v): expected %q, got %q", tt.val, tt.expected, got)
+		}
+	}
+}
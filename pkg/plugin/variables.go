@@ -0,0 +1,235 @@
+package plugin
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// queryTypeVariable marks queries issued by dashboard variables.
+const queryTypeVariable = "variable"
+
+const (
+	variableTextField  = "__text"
+	variableValueField = "__value"
+)
+
+type variableOption struct {
+	text       string
+	value      string
+	properties []*string
+}
+
+// VariableFrame converts a rqlite result to a variable options frame with
+// __text and __value fields.
+//
+// If the result has a __text or __value column, each row becomes one option
+// and any other columns are kept as additional option properties, e.g. for
+// grouping. Otherwise the values of all columns are flattened into options
+// whose text and value are the same.
+//
+// If regex is set, only options whose text matches are kept. Named capture
+// groups "text" and "value" replace the option text and value, otherwise the
+// first capture group replaces both. Options are then de-duplicated and sorted.
+func VariableFrame(result *RqliteResult, regex, sortOrder string) (*data.Frame, error) {
+	if result.Error != "" {
+		return nil, fmt.Errorf("rqlite query error: %s", result.Error)
+	}
+
+	var re *regexp.Regexp
+	if regex != "" {
+		var err error
+		if re, err = regexp.Compile(regex); err != nil {
+			return nil, fmt.Errorf("invalid variable regex: %w", err)
+		}
+	}
+
+	textIdx := slices.Index(result.Columns, variableTextField)
+	valueIdx := slices.Index(result.Columns, variableValueField)
+
+	var propertyNames []string
+	var options []variableOption
+	if textIdx >= 0 || valueIdx >= 0 {
+		if textIdx < 0 {
+			textIdx = valueIdx
+		}
+		if valueIdx < 0 {
+			valueIdx = textIdx
+		}
+
+		var propertyIdxs []int
+		for i, col := range result.Columns {
+			if i != textIdx && i != valueIdx {
+				propertyNames = append(propertyNames, col)
+				propertyIdxs = append(propertyIdxs, i)
+			}
+		}
+
+		for _, row := range result.Values {
+			text, ok := cell(row, textIdx)
+			if !ok {
+				continue
+			}
+			value, _ := cell(row, valueIdx)
+			opt := variableOption{text: text, value: value}
+			for _, idx := range propertyIdxs {
+				if v, ok := cell(row, idx); ok {
+					opt.properties = append(opt.properties, &v)
+				} else {
+					opt.properties = append(opt.properties, nil)
+				}
+			}
+			options = append(options, opt)
+		}
+	} else {
+		for colIdx := range result.Columns {
+			for _, row := range result.Values {
+				if v, ok := cell(row, colIdx); ok {
+					options = append(options, variableOption{text: v, value: v})
+				}
+			}
+		}
+	}
+
+	if re != nil {
+		options = filterVariableOptions(options, re)
+	}
+	options = dedupeVariableOptions(options)
+	sortVariableOptions(options, sortOrder)
+
+	texts := make([]string, len(options))
+	values := make([]string, len(options))
+	properties := make([][]*string, len(propertyNames))
+	for i, opt := range options {
+		texts[i] = opt.text
+		values[i] = opt.value
+		for p := range propertyNames {
+			properties[p] = append(properties[p], opt.properties[p])
+		}
+	}
+
+	frame := data.NewFrame("response",
+		data.NewField(variableTextField, nil, texts),
+		data.NewField(variableValueField, nil, values),
+	)
+	for p, name := range propertyNames {
+		values := properties[p]
+		if values == nil {
+			values = []*string{}
+		}
+		frame.Fields = append(frame.Fields, data.NewField(name, nil, values))
+	}
+
+	return frame, nil
+}
+
+// cell returns the string form of row[idx], reporting false for missing or NULL values.
+func cell(row []interface{}, idx int) (string, bool) {
+	val := cellValue(row, idx)
+	if val == nil {
+		return "", false
+	}
+	return formatValue(val), true
+}
+
+func filterVariableOptions(options []variableOption, re *regexp.Regexp) []variableOption {
+	textGroup := re.SubexpIndex("text")
+	valueGroup := re.SubexpIndex("value")
+
+	filtered := options[:0]
+	for _, opt := range options {
+		match := re.FindStringSubmatch(opt.text)
+		if match == nil {
+			continue
+		}
+
+		switch {
+		case textGroup >= 0 || valueGroup >= 0:
+			if textGroup >= 0 && match[textGroup] != "" {
+				opt.text = match[textGroup]
+			}
+			if valueGroup >= 0 && match[valueGroup] != "" {
+				opt.value = match[valueGroup]
+			}
+		case len(match) > 1:
+			opt.text = match[1]
+			opt.value = match[1]
+		}
+
+		filtered = append(filtered, opt)
+	}
+
+	return filtered
+}
+
+func dedupeVariableOptions(options []variableOption) []variableOption {
+	type key struct{ text, value string }
+	seen := make(map[key]bool, len(options))
+
+	deduped := options[:0]
+	for _, opt := range options {
+		k := key{opt.text, opt.value}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		deduped = append(deduped, opt)
+	}
+
+	return deduped
+}
+
+// sortVariableOptions sorts options by text. Supported orders are
+// "alphabetical-asc", "alphabetical-desc", "alphabetical-ci-asc",
+// "alphabetical-ci-desc", "numerical-asc" and "numerical-desc"; anything else
+// keeps the query order. Numerical orders keep non-numeric text last, in
+// query order, in both directions.
+func sortVariableOptions(options []variableOption, sortOrder string) {
+	var compare func(a, b variableOption) int
+	desc := strings.HasSuffix(sortOrder, "-desc")
+
+	switch strings.TrimSuffix(strings.TrimSuffix(sortOrder, "-asc"), "-desc") {
+	case "alphabetical":
+		compare = func(a, b variableOption) int { return strings.Compare(a.text, b.text) }
+	case "alphabetical-ci":
+		compare = func(a, b variableOption) int {
+			return strings.Compare(strings.ToLower(a.text), strings.ToLower(b.text))
+		}
+	case "numerical":
+		compare = func(a, b variableOption) int {
+			af, aNum := numericSortKey(a.text)
+			bf, bNum := numericSortKey(b.text)
+			switch {
+			case aNum && !bNum:
+				return -1
+			case !aNum && bNum:
+				return 1
+			case desc:
+				return cmp.Compare(bf, af)
+			default:
+				return cmp.Compare(af, bf)
+			}
+		}
+	default:
+		return
+	}
+
+	if desc && !strings.HasPrefix(sortOrder, "numerical") {
+		asc := compare
+		compare = func(a, b variableOption) int { return asc(b, a) }
+	}
+
+	slices.SortStableFunc(options, compare)
+}
+
+// numericSortKey parses the number in s, reporting false for non-numeric
+// text.
+func numericSortKey(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f, err == nil
+}
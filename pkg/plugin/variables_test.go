@@ -0,0 +1,150 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func fieldStrings(t *testing.T, frame *data.Frame, name string) []string {
+	t.Helper()
+
+	field, idx := frame.FieldByName(name)
+	if idx < 0 {
+		t.Fatalf("missing field %q", name)
+	}
+
+	values := make([]string, field.Len())
+	for i := range values {
+		switch v := field.At(i).(type) {
+		case string:
+			values[i] = v
+		case *string:
+			if v != nil {
+				values[i] = *v
+			}
+		}
+	}
+	return values
+}
+
+func TestVariableFrame_TextValueColumns(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"__value", "__text", "dc"},
+		Values: [][]interface{}{
+			{float64(1), "web-1", "fra"},
+			{float64(2), "web-2", "ams"},
+			{float64(1), "web-1", "fra"},
+			{float64(3), nil, "ams"},
+		},
+	}
+
+	frame, err := VariableFrame(result, "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := fieldStrings(t, frame, "__text"); !reflect.DeepEqual(got, []string{"web-1", "web-2"}) {
+		t.Errorf("unexpected texts: %v", got)
+	}
+	if got := fieldStrings(t, frame, "__value"); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("unexpected values: %v", got)
+	}
+	if got := fieldStrings(t, frame, "dc"); !reflect.DeepEqual(got, []string{"fra", "ams"}) {
+		t.Errorf("unexpected dc properties: %v", got)
+	}
+}
+
+func TestVariableFrame_FlattensColumns(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"a", "b"},
+		Values: [][]interface{}{
+			{"x", "y"},
+			{"z", "x"},
+		},
+	}
+
+	frame, err := VariableFrame(result, "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := fieldStrings(t, frame, "__text"); !reflect.DeepEqual(got, []string{"x", "z", "y"}) {
+		t.Errorf("unexpected texts: %v", got)
+	}
+	if got := fieldStrings(t, frame, "__value"); !reflect.DeepEqual(got, []string{"x", "z", "y"}) {
+		t.Errorf("unexpected values: %v", got)
+	}
+}
+
+func TestVariableFrame_Regex(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"host"},
+		Values:  [][]interface{}{{"web-1.fra"}, {"db-1.fra"}, {"web-2.ams"}},
+	}
+
+	tests := []struct {
+		name           string
+		regex          string
+		expectedTexts  []string
+		expectedValues []string
+	}{
+		{"filter", `^web`, []string{"web-1.fra", "web-2.ams"}, []string{"web-1.fra", "web-2.ams"}},
+		{"capture group", `^(web-\d)`, []string{"web-1", "web-2"}, []string{"web-1", "web-2"}},
+		{"named groups", `^(?P<value>web-\d)\.(?P<text>\w+)`, []string{"fra", "ams"}, []string{"web-1", "web-2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := VariableFrame(result, tt.regex, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := fieldStrings(t, frame, "__text"); !reflect.DeepEqual(got, tt.expectedTexts) {
+				t.Errorf("expected texts %v, got %v", tt.expectedTexts, got)
+			}
+			if got := fieldStrings(t, frame, "__value"); !reflect.DeepEqual(got, tt.expectedValues) {
+				t.Errorf("expected values %v, got %v", tt.expectedValues, got)
+			}
+		})
+	}
+}
+
+func TestVariableFrame_InvalidRegex(t *testing.T) {
+	_, err := VariableFrame(&RqliteResult{Columns: []string{"a"}}, "(", "")
+	if err == nil {
+		t.Fatal("expected error for invalid regex")
+	}
+}
+
+func TestVariableFrame_Sort(t *testing.T) {
+	result := &RqliteResult{
+		Columns: []string{"v"},
+		Values:  [][]interface{}{{"10"}, {"b"}, {"9"}, {"A"}},
+	}
+
+	tests := []struct {
+		sort     string
+		expected []string
+	}{
+		{"", []string{"10", "b", "9", "A"}},
+		{"alphabetical-asc", []string{"10", "9", "A", "b"}},
+		{"alphabetical-desc", []string{"b", "A", "9", "10"}},
+		{"alphabetical-ci-asc", []string{"10", "9", "A", "b"}},
+		{"numerical-asc", []string{"9", "10", "b", "A"}},
+		{"numerical-desc", []string{"10", "9", "b", "A"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			frame, err := VariableFrame(result, "", tt.sort)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := fieldStrings(t, frame, "__text"); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
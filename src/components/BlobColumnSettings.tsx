@@ -0,0 +1,64 @@
+import React from 'react';
+import { Button, Combobox, type ComboboxOption, InlineField, InlineFieldRow, Input } from '@grafana/ui';
+import { BlobMode } from '../types';
+
+interface Props {
+  value: Record<string, BlobMode>;
+  modeOptions: Array<ComboboxOption<string>>;
+  onChange: (blobColumns: Record<string, BlobMode>) => void;
+  onRunQuery: () => void;
+}
+
+// Rendering of BLOB columns by name, overriding the query's BLOB mode
+export function BlobColumnSettings({ value, modeOptions, onChange, onRunQuery }: Props) {
+  const entries = Object.entries(value);
+
+  const update = (index: number, name: string, mode: BlobMode) => {
+    const next = entries.map((entry, i): [string, BlobMode] => (i === index ? [name, mode] : entry));
+    onChange(Object.fromEntries(next));
+  };
+
+  const remove = (index: number) => {
+    onChange(Object.fromEntries(entries.filter((_, i) => i !== index)));
+    onRunQuery();
+  };
+
+  return (
+    <>
+      {entries.map(([name, mode], i) => (
+        <InlineFieldRow key={i}>
+          <InlineField label="Column" labelWidth={12}>
+            <Input
+              aria-label="BLOB column"
+              value={name}
+              onChange={(e) => update(i, e.currentTarget.value.trim(), mode)}
+              onBlur={onRunQuery}
+              width={24}
+            />
+          </InlineField>
+          <InlineField label="Render as" labelWidth={12}>
+            <Combobox
+              options={modeOptions}
+              value={mode}
+              onChange={(option) => {
+                update(i, name, option.value as BlobMode);
+                onRunQuery();
+              }}
+              width={20}
+            />
+          </InlineField>
+          <Button variant="secondary" icon="trash-alt" aria-label="Remove BLOB column" onClick={() => remove(i)} />
+        </InlineFieldRow>
+      ))}
+      <Button
+        variant="secondary"
+        size="sm"
+        icon="plus"
+        onClick={() => onChange({ ...value, '': 'base64' })}
+        disabled={'' in value}
+      >
+        Add column
+      </Button>
+    </>
+  );
+}
@@ -1,4 +1,4 @@
-import React, { ChangeEvent } from 'react';
+import React, { ChangeEvent, useState } from 'react';
 import {
   AdvancedHttpSettings,
   Auth,
@@ -8,10 +8,32 @@ import {
   convertLegacyAuthProps,
 } from '@grafana/plugin-ui';
 import { DataSourcePluginOptionsEditorProps } from '@grafana/data';
-import { Combobox, type ComboboxOption, Divider, InlineField, Input, Stack } from '@grafana/ui';
-import { RqliteDataSourceOptions } from '../types';
+import {
+  Combobox,
+  type ComboboxOption,
+  Divider,
+  InlineField,
+  InlineSwitch,
+  Input,
+  SecretTextArea,
+  Stack,
+  TagsInput,
+  TextArea,
+} from '@grafana/ui';
+import { RqliteDataSourceOptions, RqliteSecureJsonData } from '../types';
+
+interface Props extends DataSourcePluginOptionsEditorProps<RqliteDataSourceOptions, RqliteSecureJsonData> {}
 
-interface Props extends DataSourcePluginOptionsEditorProps<RqliteDataSourceOptions> {}
+type NumberSetting =
+  | 'maxRows'
+  | 'maxResponseBytes'
+  | 'maxRetries'
+  | 'breakerErrorRate'
+  | 'maxConcurrentQueries'
+  | 'splitConcurrency'
+  | 'chunkCacheSize'
+  | 'largeTableRows';
+type DurationSetting = 'liveInterval' | 'breakerLatency' | 'breakerCooldown';
 
 const consistencyOptions: Array<ComboboxOption<string>> = [
   { label: 'None', value: 'none', description: 'No consistency guarantee' },
@@ -20,9 +42,24 @@ const consistencyOptions: Array<ComboboxOption<string>> = [
   { label: 'Strong', value: 'strong', description: 'Strong consistency - Raft consensus' },
 ];
 
+const limitActionOptions: Array<ComboboxOption<string>> = [
+  { label: 'Truncate (default)', value: 'truncate', description: 'Return the rows up to the limit with a warning' },
+  { label: 'Reject', value: 'reject', description: 'Fail the query with an error' },
+];
+
+const auditSqlOptions: Array<ComboboxOption<string>> = [
+  { label: 'Normalized (default)', value: 'normalized', description: 'SQL with literals replaced by placeholders' },
+  { label: 'Raw', value: 'raw', description: 'SQL as executed, including literals' },
+  { label: 'None', value: 'none', description: 'Only the statement fingerprint' },
+];
+
 export function ConfigEditor(props: Props) {
   const { onOptionsChange, options } = props;
-  const { jsonData } = options;
+  const { jsonData, secureJsonFields } = options;
+  const [tenantPolicy, setTenantPolicy] = useState(
+    jsonData.tenantPolicy ? JSON.stringify(jsonData.tenantPolicy, null, 2) : ''
+  );
+  const [tenantPolicyError, setTenantPolicyError] = useState('');
 
   const onConsistencyChange = (option: ComboboxOption<string>) => {
     onOptionsChange({
@@ -44,6 +81,120 @@ export function ConfigEditor(props: Props) {
     });
   };
 
+  const onAuditLogChange = (event: React.FormEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...jsonData,
+        auditLog: event.currentTarget.checked,
+      },
+    });
+  };
+
+  const onAuditSqlChange = (option: ComboboxOption<string>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...jsonData,
+        auditSql: option.value as RqliteDataSourceOptions['auditSql'],
+      },
+    });
+  };
+
+  const onIdentityForwardingChange = (event: React.FormEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...jsonData,
+        identityForwarding: event.currentTarget.checked,
+      },
+    });
+  };
+
+  const onIdentityMapChange = (event: ChangeEvent<HTMLTextAreaElement>) => {
+    onOptionsChange({
+      ...options,
+      secureJsonData: {
+        ...options.secureJsonData,
+        identityMap: event.target.value,
+      },
+    });
+  };
+
+  const onIdentityMapReset = () => {
+    onOptionsChange({
+      ...options,
+      secureJsonFields: {
+        ...secureJsonFields,
+        identityMap: false,
+      },
+      secureJsonData: {
+        ...options.secureJsonData,
+        identityMap: '',
+      },
+    });
+  };
+
+  const onTenantPolicyBlur = () => {
+    let policy;
+    try {
+      policy = tenantPolicy.trim() ? JSON.parse(tenantPolicy) : undefined;
+    } catch (err) {
+      setTenantPolicyError(err instanceof Error ? err.message : String(err));
+      return;
+    }
+    setTenantPolicyError('');
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...jsonData,
+        tenantPolicy: policy,
+      },
+    });
+  };
+
+  const onTablePatternsChange = (key: 'allowedTables' | 'deniedTables') => (patterns: string[]) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...jsonData,
+        [key]: patterns.length > 0 ? patterns : undefined,
+      },
+    });
+  };
+
+  const onDurationChange = (key: DurationSetting) => (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...jsonData,
+        [key]: event.target.value.trim() || undefined,
+      },
+    });
+  };
+
+  const onNumberChange = (key: NumberSetting) => (event: ChangeEvent<HTMLInputElement>) => {
+    const value =
+      key === 'breakerErrorRate' ? Number.parseFloat(event.target.value) : Number.parseInt(event.target.value, 10);
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...jsonData,
+        [key]: Number.isNaN(value) ? undefined : value,
+      },
+    });
+  };
+
+  const onLimitActionChange = (option: ComboboxOption<string>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...jsonData,
+        limitAction: option.value === 'reject' ? 'reject' : 'truncate',
+      },
+    });
+  };
+
   return (
     <>
       <ConnectionSettings config={options} onChange={onOptionsChange} urlPlaceholder="http://localhost:4001" />
@@ -56,6 +207,83 @@ export function ConfigEditor(props: Props) {
         })}
       />
 
+      <Divider spacing={4} />
+      <ConfigSection
+        title="Identity forwarding"
+        description="Run each query as the rqlite user mapped to the Grafana user, so rqlite's permissions apply per user."
+        isCollapsible={true}
+        isInitiallyOpen={jsonData.identityForwarding ?? false}
+      >
+        <InlineField label="Forward identity" labelWidth={20} tooltip="Use the identity map instead of the shared credentials">
+          <InlineSwitch value={jsonData.identityForwarding ?? false} onChange={onIdentityForwardingChange} />
+        </InlineField>
+        <InlineField
+          label="Identity map"
+          labelWidth={20}
+          tooltip='JSON with "users" by login, "roles" by org role and a "default", each mapping to {"username", "password"}'
+          disabled={!jsonData.identityForwarding}
+        >
+          <SecretTextArea
+            isConfigured={secureJsonFields?.identityMap ?? false}
+            onChange={onIdentityMapChange}
+            onReset={onIdentityMapReset}
+            placeholder='{"users": {"alice": {"username": "alice", "password": "..."}}, "default": {...}}'
+            rows={6}
+            cols={60}
+          />
+        </InlineField>
+      </ConfigSection>
+
+      <Divider spacing={4} />
+      <ConfigSection
+        title="Table access"
+        description="Expose only some tables through this data source. Patterns may use * and ? wildcards."
+        isCollapsible={true}
+        isInitiallyOpen={Boolean(jsonData.allowedTables?.length || jsonData.deniedTables?.length)}
+      >
+        <InlineField label="Allowed tables" labelWidth={20} tooltip="If set, only tables matching a pattern are exposed">
+          <TagsInput
+            tags={jsonData.allowedTables ?? []}
+            onChange={onTablePatternsChange('allowedTables')}
+            placeholder="All tables"
+            width={60}
+          />
+        </InlineField>
+        <InlineField label="Denied tables" labelWidth={20} tooltip="Tables matching a pattern are never exposed">
+          <TagsInput
+            tags={jsonData.deniedTables ?? []}
+            onChange={onTablePatternsChange('deniedTables')}
+            placeholder="No tables"
+            width={60}
+          />
+        </InlineField>
+      </ConfigSection>
+
+      <Divider spacing={4} />
+      <ConfigSection
+        title="Tenant filtering"
+        description="Restrict each user to the rows of their tenant in protected tables."
+        isCollapsible={true}
+        isInitiallyOpen={jsonData.tenantPolicy !== undefined}
+      >
+        <InlineField
+          label="Tenant policy"
+          labelWidth={20}
+          tooltip='JSON with tenants by login in "users" and by org ID in "orgs", and the tenant column of each protected table in "tables"'
+          invalid={tenantPolicyError !== ''}
+          error={tenantPolicyError}
+        >
+          <TextArea
+            value={tenantPolicy}
+            onChange={(event: ChangeEvent<HTMLTextAreaElement>) => setTenantPolicy(event.target.value)}
+            onBlur={onTenantPolicyBlur}
+            placeholder='{"users": {"alice": "blue"}, "orgs": {"1": "green"}, "tables": {"events": "team"}}'
+            rows={6}
+            cols={60}
+          />
+        </InlineField>
+      </ConfigSection>
+
       <Divider spacing={4} />
       <ConfigSection
         title="Additional settings"
@@ -78,6 +306,154 @@ export function ConfigEditor(props: Props) {
             <InlineField label="Query Timeout" labelWidth={20} tooltip="Query timeout (e.g. 10s, 30s)">
               <Input value={jsonData.timeout || ''} onChange={onTimeoutChange} placeholder="10s" width={30} />
             </InlineField>
+            <InlineField
+              label="Max rows"
+              labelWidth={20}
+              tooltip="Maximum number of rows per query result, empty for no limit"
+            >
+              <Input
+                type="number"
+                value={jsonData.maxRows ?? ''}
+                onChange={onNumberChange('maxRows')}
+                placeholder="No limit"
+                width={30}
+              />
+            </InlineField>
+            <InlineField
+              label="Max response bytes"
+              labelWidth={20}
+              tooltip="Maximum size of a query response from rqlite in bytes, empty for no limit"
+            >
+              <Input
+                type="number"
+                value={jsonData.maxResponseBytes ?? ''}
+                onChange={onNumberChange('maxResponseBytes')}
+                placeholder="No limit"
+                width={30}
+              />
+            </InlineField>
+            <InlineField label="Limit action" labelWidth={20} tooltip="What to do when a result exceeds a limit">
+              <Combobox
+                options={limitActionOptions}
+                value={jsonData.limitAction || 'truncate'}
+                onChange={onLimitActionChange}
+                width={30}
+              />
+            </InlineField>
+            <InlineField
+              label="Max retries"
+              labelWidth={20}
+              tooltip="Retries of queries failing with a transient error such as a leader election, -1 to disable"
+            >
+              <Input
+                type="number"
+                value={jsonData.maxRetries ?? ''}
+                onChange={onNumberChange('maxRetries')}
+                placeholder="3"
+                width={30}
+              />
+            </InlineField>
+            <InlineField
+              label="Max concurrent queries"
+              labelWidth={20}
+              tooltip="Maximum number of queries in flight to rqlite, -1 for no limit"
+            >
+              <Input
+                type="number"
+                value={jsonData.maxConcurrentQueries ?? ''}
+                onChange={onNumberChange('maxConcurrentQueries')}
+                placeholder="10"
+                width={30}
+              />
+            </InlineField>
+            <InlineField
+              label="Breaker error rate"
+              labelWidth={20}
+              tooltip="Share of failed recent queries, between 0 and 1, at which queries fail fast until rqlite recovers. -1 disables the circuit breaker"
+            >
+              <Input
+                type="number"
+                step={0.05}
+                value={jsonData.breakerErrorRate ?? ''}
+                onChange={onNumberChange('breakerErrorRate')}
+                placeholder="0.5"
+                width={30}
+              />
+            </InlineField>
+            <InlineField label="Breaker latency" labelWidth={20} tooltip="Queries slower than this count as failed">
+              <Input
+                value={jsonData.breakerLatency || ''}
+                onChange={onDurationChange('breakerLatency')}
+                placeholder="Off"
+                width={30}
+              />
+            </InlineField>
+            <InlineField
+              label="Breaker cooldown"
+              labelWidth={20}
+              tooltip="How long queries fail fast before a probe query is sent"
+            >
+              <Input
+                value={jsonData.breakerCooldown || ''}
+                onChange={onDurationChange('breakerCooldown')}
+                placeholder="30s"
+                width={30}
+              />
+            </InlineField>
+            <InlineField label="Live interval" labelWidth={20} tooltip="How often live queries poll for new rows">
+              <Input value={jsonData.liveInterval || ''} onChange={onDurationChange('liveInterval')} placeholder="5s" width={30} />
+            </InlineField>
+            <InlineField
+              label="Split concurrency"
+              labelWidth={20}
+              tooltip="How many chunks of a split query run at the same time"
+            >
+              <Input
+                type="number"
+                value={jsonData.splitConcurrency ?? ''}
+                onChange={onNumberChange('splitConcurrency')}
+                placeholder="4"
+                width={30}
+              />
+            </InlineField>
+            <InlineField
+              label="Chunk cache size"
+              labelWidth={20}
+              tooltip="Number of historical chunks of split queries kept in memory, -1 to disable the cache"
+            >
+              <Input
+                type="number"
+                value={jsonData.chunkCacheSize ?? ''}
+                onChange={onNumberChange('chunkCacheSize')}
+                placeholder="100"
+                width={30}
+              />
+            </InlineField>
+            <InlineField
+              label="Large table rows"
+              labelWidth={20}
+              tooltip="Row count from which full table scans are warned about when explaining a query"
+            >
+              <Input
+                type="number"
+                value={jsonData.largeTableRows ?? ''}
+                onChange={onNumberChange('largeTableRows')}
+                placeholder="100000"
+                width={30}
+              />
+            </InlineField>
+            <InlineField label="Audit log" labelWidth={20} tooltip="Log who ran which query, with the outcome">
+              <InlineSwitch value={jsonData.auditLog ?? false} onChange={onAuditLogChange} />
+            </InlineField>
+            <InlineField label="Audit SQL" labelWidth={20} tooltip="How much of the SQL the audit log records">
+              <Combobox
+                options={auditSqlOptions}
+                value={jsonData.auditSql || 'normalized'}
+                onChange={onAuditSqlChange}
+                disabled={!jsonData.auditLog}
+                width={30}
+              />
+            </InlineField>
           </ConfigSubSection>
         </Stack>
       </ConfigSection>
@@ -1,16 +1,19 @@
-import React, { useCallback, useEffect, useMemo, useState } from 'react';
+import React, { useCallback, useEffect, useMemo, useRef, useState } from 'react';
 import { css } from '@emotion/css';
-import { GrafanaTheme2, QueryEditorProps, SelectableValue } from '@grafana/data';
+import { getDefaultTimeRange, GrafanaTheme2, QueryEditorProps, SelectableValue } from '@grafana/data';
 import {
+  type Monaco,
   type MonacoEditor,
   CodeEditor,
   InlineField,
   InlineFieldRow,
+  InlineSwitch,
   Input,
   RadioButtonGroup,
   Combobox,
   type ComboboxOption,
   Collapse,
+  Alert,
   Modal,
   Button,
   ConfirmModal,
@@ -24,9 +27,16 @@ import {
   RqliteQuery,
   EditorMode,
   QueryFormat,
+  BlobMode,
+  DownsampleMode,
+  ExplainResponse,
+  SQLError,
   ColumnSelection,
   WhereCondition,
   OrderByClause,
+  TimeColumn,
+  timeColumnName,
+  VARIABLE_QUERY_TYPE,
 } from '../types';
 import { TableSelect } from './visual-query-builder/TableSelect';
 import { ColumnSelect } from './visual-query-builder/ColumnSelect';
@@ -36,9 +46,16 @@ import { GroupBySelect } from './visual-query-builder/GroupBySelect';
 import { SQLPreview } from './visual-query-builder/SQLPreview';
 import { generateSQL } from './visual-query-builder/sqlGenerator';
 import { QUERY_CODE_EDITOR_HEIGHT } from './codeEditorHeights';
+import { VariableQueryFields } from './VariableQueryFields';
+import { QueryPlan } from './QueryPlan';
+import { TimeColumnSettings } from './TimeColumnSettings';
+import { BlobColumnSettings } from './BlobColumnSettings';
 
 type Props = QueryEditorProps<DataSource, RqliteQuery, RqliteDataSourceOptions>;
 
+// Delay after the last edit before the SQL is validated
+const VALIDATE_DELAY_MS = 500;
+
 const editorModeOptions: Array<SelectableValue<EditorMode>> = [
   { label: 'Code', value: 'code' },
   { label: 'Builder', value: 'builder' },
@@ -49,12 +66,34 @@ const formatOptions: Array<ComboboxOption<string>> = [
   { label: 'Time series', value: 'time_series' },
 ];
 
-export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props) {
+const downsampleOptions: Array<ComboboxOption<string>> = [
+  { label: 'Off', value: '' },
+  { label: 'LTTB', value: 'lttb', description: 'Largest-Triangle-Three-Buckets, keeps the visual shape' },
+  { label: 'Min/max', value: 'minmax', description: 'Minimum and maximum per bucket, keeps spikes' },
+];
+
+const blobModeOptions: Array<ComboboxOption<string>> = [
+  { label: 'Base64', value: 'base64' },
+  { label: 'Hex', value: 'hex' },
+  { label: 'UTF-8', value: 'utf8', description: 'Decode as text, replacing invalid bytes' },
+  { label: 'Length', value: 'length', description: 'Number of bytes' },
+  { label: 'Data URI', value: 'datauri', description: 'For images, with a detected content type' },
+];
+
+export function QueryEditor({ query, onChange, onRunQuery, datasource, range }: Props) {
   const styles = useStyles2(getStyles);
   const {
     rawSql = '',
     format = 'table',
     timeColumns = ['time'],
+    detectTimeColumns = false,
+    downsample = '',
+    blobMode = 'base64',
+    blobColumns = {},
+    inferBooleans = false,
+    splitDuration = '',
+    live = false,
+    liveColumn = '',
     editorMode = 'code',
     table = '',
     columns = [],
@@ -73,6 +112,11 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
   const [expandedEditor, setExpandedEditor] = useState(false);
   const [expandedSql, setExpandedSql] = useState(rawSql);
   const [confirmSwitchOpen, setConfirmSwitchOpen] = useState(false);
+  const [timeSettingsOpen, setTimeSettingsOpen] = useState(timeColumns.some((col) => typeof col !== 'string'));
+  const [blobSettingsOpen, setBlobSettingsOpen] = useState(Object.keys(blobColumns).length > 0);
+  const [explain, setExplain] = useState<ExplainResponse>();
+  const [explainError, setExplainError] = useState('');
+  const [explaining, setExplaining] = useState(false);
 
   // Generate SQL from builder state and sync to rawSql
   const generatedSQL = useMemo(
@@ -110,12 +154,84 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
     [onChange, onRunQuery, query]
   );
 
+  const onDownsampleChange = useCallback(
+    (option: ComboboxOption<string>) => {
+      onChange({ ...query, downsample: option.value as DownsampleMode });
+      onRunQuery();
+    },
+    [onChange, onRunQuery, query]
+  );
+
+  const onBlobModeChange = useCallback(
+    (option: ComboboxOption<string>) => {
+      onChange({ ...query, blobMode: option.value as BlobMode });
+      onRunQuery();
+    },
+    [onChange, onRunQuery, query]
+  );
+
+  const onBlobColumnsChange = useCallback(
+    (cols: Record<string, BlobMode>) => {
+      onChange({ ...query, blobColumns: cols });
+    },
+    [onChange, query]
+  );
+
+  const onSplitDurationChange = useCallback(
+    (event: React.ChangeEvent<HTMLInputElement>) => {
+      onChange({ ...query, splitDuration: event.target.value.trim() });
+    },
+    [onChange, query]
+  );
+
+  const onInferBooleansChange = useCallback(
+    (event: React.FormEvent<HTMLInputElement>) => {
+      onChange({ ...query, inferBooleans: event.currentTarget.checked });
+      onRunQuery();
+    },
+    [onChange, onRunQuery, query]
+  );
+
+  const onLiveChange = useCallback(
+    (event: React.FormEvent<HTMLInputElement>) => {
+      onChange({ ...query, live: event.currentTarget.checked });
+      onRunQuery();
+    },
+    [onChange, onRunQuery, query]
+  );
+
+  const onLiveColumnChange = useCallback(
+    (event: React.ChangeEvent<HTMLInputElement>) => {
+      onChange({ ...query, liveColumn: event.target.value.trim() });
+    },
+    [onChange, query]
+  );
+
+  const onDetectTimeColumnsChange = useCallback(
+    (event: React.FormEvent<HTMLInputElement>) => {
+      const detect = event.currentTarget.checked;
+      // Listed time columns override detection, so the default list is cleared.
+      onChange({ ...query, detectTimeColumns: detect, timeColumns: detect ? [] : ['time'] });
+      onRunQuery();
+    },
+    [onChange, onRunQuery, query]
+  );
+
   const onTimeColumnsChange = useCallback(
     (event: React.ChangeEvent<HTMLInputElement>) => {
       const cols = event.target.value
         .split(',')
         .map((s) => s.trim())
         .filter(Boolean);
+      // Keep the settings of columns that are still listed.
+      const settings = new Map<string, string | TimeColumn>(timeColumns.map((col) => [timeColumnName(col), col]));
+      onChange({ ...query, timeColumns: cols.map((name) => settings.get(name) ?? name) });
+    },
+    [onChange, query, timeColumns]
+  );
+
+  const onTimeColumnSettingsChange = useCallback(
+    (cols: Array<string | TimeColumn>) => {
       onChange({ ...query, timeColumns: cols });
     },
     [onChange, query]
@@ -186,8 +302,60 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
     }
   }, [rawSql, onChange, query]);
 
+  // Validates the SQL in the editor and marks its errors. Validation only
+  // helps while typing, so failures of the request are ignored.
+  const validateSql = useCallback(
+    async (editor: MonacoEditor, monaco: Monaco) => {
+      const model = editor.getModel();
+      const sql = editor.getValue();
+      if (!model) {
+        return;
+      }
+      let errors: SQLError[] = [];
+      if (sql.trim()) {
+        try {
+          const result = await datasource.validateQuery({ ...query, rawSql: sql }, range ?? getDefaultTimeRange());
+          errors = result.errors ?? [];
+        } catch {
+          return;
+        }
+      }
+      // Skip results for SQL that has been edited since.
+      if (model.isDisposed() || model.getValue() !== sql) {
+        return;
+      }
+      monaco.editor.setModelMarkers(
+        model,
+        'rqlite',
+        errors.map((error) => ({
+          severity: monaco.MarkerSeverity.Error,
+          message: error.message,
+          startLineNumber: error.line,
+          startColumn: error.column,
+          endLineNumber: error.endLine,
+          endColumn: error.endColumn,
+        }))
+      );
+    },
+    [datasource, query, range]
+  );
+
+  // The editor's change listener is registered once, so it calls the latest
+  // validateSql through a ref.
+  const validateSqlRef = useRef(validateSql);
+  validateSqlRef.current = validateSql;
+  const validateTimer = useRef<ReturnType<typeof setTimeout>>();
+  useEffect(() => () => clearTimeout(validateTimer.current), []);
+
   const onEditorDidMount = useCallback(
-    (editor: MonacoEditor) => {
+    (editor: MonacoEditor, monaco: Monaco) => {
+      const scheduleValidation = () => {
+        clearTimeout(validateTimer.current);
+        validateTimer.current = setTimeout(() => validateSqlRef.current(editor, monaco), VALIDATE_DELAY_MS);
+      };
+      editor.onDidChangeModelContent(scheduleValidation);
+      scheduleValidation();
+
       editor.addAction({
         id: 'run-query',
         label: 'Run Query',
@@ -204,6 +372,19 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
     [onRunQuery]
   );
 
+  const onExplain = useCallback(async () => {
+    setExplaining(true);
+    setExplainError('');
+    try {
+      setExplain(await datasource.explainQuery(query, range ?? getDefaultTimeRange()));
+    } catch (err) {
+      setExplain(undefined);
+      setExplainError(err instanceof Error ? err.message : 'Failed to explain the query');
+    } finally {
+      setExplaining(false);
+    }
+  }, [datasource, query, range]);
+
   const onExpandedEditorOpen = useCallback(() => {
     setExpandedSql(rawSql);
     setExpandedEditor(true);
@@ -224,9 +405,91 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
           <Combobox options={formatOptions} value={format} onChange={onFormatChange} width={20} />
         </InlineField>
         <InlineField label="Time columns" labelWidth={18} tooltip="Comma-separated list of columns to parse as time">
-          <Input value={timeColumns.join(', ')} onChange={onTimeColumnsChange} placeholder="time" width={30} />
+          <Input
+            value={timeColumns.map(timeColumnName).join(', ')}
+            onChange={onTimeColumnsChange}
+            placeholder={detectTimeColumns ? 'auto' : 'time'}
+            width={30}
+          />
+        </InlineField>
+        <InlineField
+          label="Detect time"
+          labelWidth={12}
+          tooltip="Find time columns by declared type, name and values unless time columns are listed"
+        >
+          <InlineSwitch value={detectTimeColumns} onChange={onDetectTimeColumnsChange} />
+        </InlineField>
+        {format === 'time_series' && (
+          <InlineField label="Downsample" labelWidth={14} tooltip="Reduce each series to the panel's max data points">
+            <Combobox options={downsampleOptions} value={downsample} onChange={onDownsampleChange} width={20} />
+          </InlineField>
+        )}
+        <InlineField label="BLOBs" labelWidth={8} tooltip="How BLOB columns are rendered">
+          <Combobox options={blobModeOptions} value={blobMode} onChange={onBlobModeChange} width={14} />
         </InlineField>
+        <InlineField
+          label="0/1 as bool"
+          labelWidth={12}
+          tooltip="Show integer columns holding only 0 and 1 as booleans if named like flags or with enough values"
+        >
+          <InlineSwitch value={inferBooleans} onChange={onInferBooleansChange} />
+        </InlineField>
+        <InlineField
+          label="Split"
+          labelWidth={8}
+          tooltip="Run queries with $__timeFilter in chunks of this length, such as 1d, and merge the results"
+        >
+          <Input
+            value={splitDuration}
+            onChange={onSplitDurationChange}
+            onBlur={onRunQuery}
+            placeholder="Off"
+            width={10}
+          />
+        </InlineField>
+        <InlineField label="Live" labelWidth={8} tooltip="Stream new rows instead of waiting for a refresh">
+          <InlineSwitch value={live} onChange={onLiveChange} />
+        </InlineField>
+        {live && (
+          <InlineField
+            label="Live column"
+            labelWidth={14}
+            tooltip="Increasing column, such as a time column or rowid, that marks the rows already sent"
+          >
+            <Input
+              value={liveColumn}
+              onChange={onLiveColumnChange}
+              onBlur={onRunQuery}
+              placeholder={timeColumns.length > 0 ? timeColumnName(timeColumns[0]) : undefined}
+              width={20}
+            />
+          </InlineField>
+        )}
       </InlineFieldRow>
+      {timeColumns.length > 0 && (
+        <Collapse
+          label="Time column settings"
+          isOpen={timeSettingsOpen}
+          onToggle={() => setTimeSettingsOpen(!timeSettingsOpen)}
+        >
+          <TimeColumnSettings value={timeColumns} onChange={onTimeColumnSettingsChange} onRunQuery={onRunQuery} />
+        </Collapse>
+      )}
+      <Collapse
+        label="BLOB column settings"
+        isOpen={blobSettingsOpen}
+        onToggle={() => setBlobSettingsOpen(!blobSettingsOpen)}
+      >
+        <BlobColumnSettings
+          value={blobColumns}
+          modeOptions={blobModeOptions}
+          onChange={onBlobColumnsChange}
+          onRunQuery={onRunQuery}
+        />
+      </Collapse>
+      {query.queryType === VARIABLE_QUERY_TYPE && (
+        <VariableQueryFields query={query} onChange={onChange} onRunQuery={onRunQuery} />
+      )}
 
       {editorMode === 'code' && (
         <>
@@ -237,6 +500,16 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
             <Button variant="secondary" size="sm" icon="expand-arrows" onClick={onExpandedEditorOpen}>
               Expand
             </Button>
+            <Button
+              variant="secondary"
+              size="sm"
+              icon={explaining ? 'spinner' : 'list-ul'}
+              onClick={onExplain}
+              disabled={!rawSql.trim() || explaining}
+              tooltip="Show the query plan and warn about full scans of large tables"
+            >
+              Explain
+            </Button>
             <span className={styles.editorHint}>Ctrl+Enter to run</span>
           </Stack>
           <CodeEditor
@@ -249,13 +522,24 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
             showMiniMap={false}
             showLineNumbers
           />
+          {explainError && (
+            <Alert severity="error" title="Explain failed" onRemove={() => setExplainError('')}>
+              {explainError}
+            </Alert>
+          )}
+          {explain && (
+            <Collapse label="Query Plan" isOpen onToggle={() => setExplain(undefined)}>
+              <QueryPlan explain={explain} />
+            </Collapse>
+          )}
           <Collapse label="Macro Reference" isOpen={macroRefOpen} onToggle={() => setMacroRefOpen(!macroRefOpen)}>
             <pre className={styles.macroReference}>
               {`$__timeFilter(column)  → column >= <from> AND column <= <to>
 $__timeFrom           → Unix epoch seconds (from)
 $__timeTo             → Unix epoch seconds (to)
 $__timeGroup(col, 5m) → (CAST(col / 300 AS INTEGER) * 300)
-$__unixEpochFilter(c) → alias for $__timeFilter`}
+$__unixEpochFilter(c) → alias for $__timeFilter
+$__tenantFilter(col)  → col = '<tenant of the user>'`}
             </pre>
           </Collapse>
           <Modal title="Edit SQL" isOpen={expandedEditor} onDismiss={() => setExpandedEditor(false)}>
@@ -285,7 +569,14 @@ $__unixEpochFilter(c) → alias for $__timeFilter`}
           <TableSelect datasource={datasource} value={table} onChange={onTableChange} />
           <ColumnSelect datasource={datasource} table={table} value={columns} onChange={onColumnsChange} />
           <Collapse label="WHERE" isOpen={whereOpen} onToggle={() => setWhereOpen(!whereOpen)}>
-            <WhereEditor datasource={datasource} table={table} value={whereClause} onChange={onWhereChange} />
+            <WhereEditor
+              datasource={datasource}
+              table={table}
+              value={whereClause}
+              onChange={onWhereChange}
+              range={range}
+              timeColumn={timeColumns.length > 0 ? timeColumnName(timeColumns[0]) : undefined}
+            />
           </Collapse>
           <Collapse label="GROUP BY" isOpen={groupByOpen} onToggle={() => setGroupByOpen(!groupByOpen)}>
             <GroupBySelect datasource={datasource} table={table} value={groupBy} onChange={onGroupByChange} />
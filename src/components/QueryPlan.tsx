@@ -0,0 +1,40 @@
+import React from 'react';
+import { css } from '@emotion/css';
+import { GrafanaTheme2 } from '@grafana/data';
+import { Alert, useStyles2 } from '@grafana/ui';
+import { ExplainResponse, PlanNode } from '../types';
+
+interface Props {
+  explain: ExplainResponse;
+}
+
+// One line per plan step, indented by its depth in the tree
+function planLines(nodes: PlanNode[], depth = 0): string[] {
+  return nodes.flatMap((node) => [`${'  '.repeat(depth)}${node.detail}`, ...planLines(node.children ?? [], depth + 1)]);
+}
+
+// Query plan of the /explain endpoint, with its warnings first
+export function QueryPlan({ explain }: Props) {
+  const styles = useStyles2(getStyles);
+  const warnings = explain.warnings ?? [];
+
+  return (
+    <div>
+      {warnings.map((warning, i) => (
+        <Alert key={i} severity={warning.severity} title={warning.message} />
+      ))}
+      {warnings.length === 0 && <Alert severity="success" title="No expensive steps found" />}
+      <pre className={styles.plan}>{planLines(explain.plan ?? []).join('\n')}</pre>
+    </div>
+  );
+}
+
+const getStyles = (theme: GrafanaTheme2) => ({
+  plan: css({
+    background: theme.colors.background.secondary,
+    fontSize: theme.typography.bodySmall.fontSize,
+    margin: 0,
+    padding: theme.spacing(1),
+    whiteSpace: 'pre-wrap',
+  }),
+});
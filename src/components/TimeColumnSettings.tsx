@@ -0,0 +1,88 @@
+import React from 'react';
+import { Combobox, type ComboboxOption, InlineField, InlineFieldRow, Input } from '@grafana/ui';
+import { TimeColumn, timeColumnName } from '../types';
+
+const unitOptions: Array<ComboboxOption<string>> = [
+  { label: 'Auto', value: '', description: 'Told by magnitude' },
+  { label: 'Seconds', value: 's' },
+  { label: 'Milliseconds', value: 'ms' },
+  { label: 'Microseconds', value: 'us' },
+  { label: 'Nanoseconds', value: 'ns' },
+];
+
+interface Props {
+  value: Array<string | TimeColumn>;
+  onChange: (timeColumns: Array<string | TimeColumn>) => void;
+  onRunQuery: () => void;
+}
+
+// Keeps a column as a plain name unless it has settings
+function compact({ name, format, unit, timezone }: TimeColumn): string | TimeColumn {
+  if (!format && !unit && !timezone) {
+    return name;
+  }
+  const col: TimeColumn = { name };
+  if (format) {
+    col.format = format;
+  }
+  if (unit) {
+    col.unit = unit;
+  }
+  if (timezone) {
+    col.timezone = timezone;
+  }
+  return col;
+}
+
+// Format, unit and time zone of each listed time column
+export function TimeColumnSettings({ value, onChange, onRunQuery }: Props) {
+  const update = (index: number, settings: Partial<TimeColumn>) => {
+    const col = value[index];
+    const current = typeof col === 'string' ? { name: col } : col;
+    onChange(value.map((c, i) => (i === index ? compact({ ...current, ...settings }) : c)));
+  };
+
+  return (
+    <>
+      {value.map((col, i) => {
+        const name = timeColumnName(col);
+        const { format = '', unit = '', timezone = '' } = typeof col === 'string' ? {} : col;
+        return (
+          <InlineFieldRow key={name}>
+            <InlineField label={name} labelWidth={18}>
+              <Input
+                aria-label={`Format of ${name}`}
+                value={format}
+                onChange={(e) => update(i, { format: e.currentTarget.value })}
+                onBlur={onRunQuery}
+                placeholder="RFC 3339 or SQLite"
+                width={30}
+              />
+            </InlineField>
+            <InlineField label="Unit" labelWidth={8} tooltip="Unit of Unix times">
+              <Combobox
+                options={unitOptions}
+                value={unit}
+                onChange={(option) => {
+                  update(i, { unit: (option.value as TimeColumn['unit']) || undefined });
+                  onRunQuery();
+                }}
+                width={20}
+              />
+            </InlineField>
+            <InlineField label="Time zone" labelWidth={12} tooltip="IANA time zone of text without an offset">
+              <Input
+                aria-label={`Time zone of ${name}`}
+                value={timezone}
+                onChange={(e) => update(i, { timezone: e.currentTarget.value.trim() })}
+                onBlur={onRunQuery}
+                placeholder="UTC"
+                width={24}
+              />
+            </InlineField>
+          </InlineFieldRow>
+        );
+      })}
+    </>
+  );
+}
@@ -0,0 +1,45 @@
+import React from 'react';
+import { fireEvent, render, screen } from '@testing-library/react';
+
+import { DEFAULT_QUERY, RqliteQuery, VARIABLE_QUERY_TYPE } from '../types';
+import { VariableQueryFields } from './VariableQueryFields';
+
+describe('VariableQueryFields', () => {
+  beforeAll(() => {
+    // The options menu of Combobox is only rendered with a size.
+    jest.spyOn(Element.prototype, 'getBoundingClientRect').mockReturnValue({
+      width: 120,
+      height: 120,
+      top: 0,
+      left: 0,
+      bottom: 0,
+      right: 0,
+    } as DOMRect);
+  });
+
+  const query = { ...DEFAULT_QUERY, refId: 'A', queryType: VARIABLE_QUERY_TYPE } as RqliteQuery;
+
+  it('changes the regex', () => {
+    const onChange = jest.fn();
+    const onRunQuery = jest.fn();
+    render(<VariableQueryFields query={query} onChange={onChange} onRunQuery={onRunQuery} />);
+
+    const input = screen.getByLabelText('Regex');
+    fireEvent.change(input, { target: { value: '^web-(.*)' } });
+    expect(onChange).toHaveBeenCalledWith({ ...query, variableRegex: '^web-(.*)' });
+
+    fireEvent.blur(input);
+    expect(onRunQuery).toHaveBeenCalled();
+  });
+
+  it('changes the sort', async () => {
+    const onChange = jest.fn();
+    const onRunQuery = jest.fn();
+    render(<VariableQueryFields query={query} onChange={onChange} onRunQuery={onRunQuery} />);
+
+    fireEvent.click(screen.getByLabelText('Sort'));
+    fireEvent.click(await screen.findByRole('option', { name: 'Numerical (desc)' }));
+    expect(onChange).toHaveBeenCalledWith({ ...query, variableSort: 'numerical-desc' });
+    expect(onRunQuery).toHaveBeenCalled();
+  });
+});
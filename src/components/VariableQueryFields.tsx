@@ -0,0 +1,62 @@
+import React, { useCallback } from 'react';
+import { Combobox, type ComboboxOption, InlineField, InlineFieldRow, Input } from '@grafana/ui';
+import { RqliteQuery, VariableSort } from '../types';
+
+const sortOptions: Array<ComboboxOption<string>> = [
+  { label: 'Disabled', value: '', description: 'Keep the order of the query' },
+  { label: 'Alphabetical (asc)', value: 'alphabetical-asc' },
+  { label: 'Alphabetical (desc)', value: 'alphabetical-desc' },
+  { label: 'Alphabetical, case-insensitive (asc)', value: 'alphabetical-ci-asc' },
+  { label: 'Alphabetical, case-insensitive (desc)', value: 'alphabetical-ci-desc' },
+  { label: 'Numerical (asc)', value: 'numerical-asc' },
+  { label: 'Numerical (desc)', value: 'numerical-desc' },
+];
+
+interface Props {
+  query: RqliteQuery;
+  onChange: (query: RqliteQuery) => void;
+  onRunQuery: () => void;
+}
+
+// Regex and sort of the options of variable queries, applied by the backend
+export function VariableQueryFields({ query, onChange, onRunQuery }: Props) {
+  const { variableRegex = '', variableSort = '' } = query;
+
+  const onRegexChange = useCallback(
+    (event: React.ChangeEvent<HTMLInputElement>) => {
+      onChange({ ...query, variableRegex: event.target.value });
+    },
+    [onChange, query]
+  );
+
+  const onSortChange = useCallback(
+    (option: ComboboxOption<string>) => {
+      onChange({ ...query, variableSort: (option.value as VariableSort) || '' });
+      onRunQuery();
+    },
+    [onChange, onRunQuery, query]
+  );
+
+  return (
+    <InlineFieldRow>
+      <InlineField
+        label="Regex"
+        labelWidth={12}
+        htmlFor="variable-regex"
+        tooltip="Keep options whose text matches. The capture groups text and value, or the first capture group, replace the option text and value."
+      >
+        <Input
+          id="variable-regex"
+          value={variableRegex}
+          onChange={onRegexChange}
+          onBlur={onRunQuery}
+          placeholder="e.g. ^web-(.*)"
+          width={40}
+        />
+      </InlineField>
+      <InlineField label="Sort" labelWidth={8} htmlFor="variable-sort" tooltip="Order of the options">
+        <Combobox id="variable-sort" options={sortOptions} value={variableSort} onChange={onSortChange} width={40} />
+      </InlineField>
+    </InlineFieldRow>
+  );
+}
@@ -1,5 +1,6 @@
 import React, { useEffect, useState } from 'react';
-import { Combobox, type ComboboxOption, InlineField, InlineFieldRow, Input, Button, IconButton } from '@grafana/ui';
+import { TimeRange } from '@grafana/data';
+import { Combobox, type ComboboxOption, InlineField, InlineFieldRow, Button, IconButton } from '@grafana/ui';
 import { DataSource } from '../../datasource';
 import { WhereCondition, ColumnInfo } from '../../types';
 
@@ -8,6 +9,9 @@ interface Props {
   table: string;
   value: WhereCondition[];
   onChange: (conditions: WhereCondition[]) => void;
+  // Value suggestions are limited to rows of this time range on timeColumn
+  range?: TimeRange;
+  timeColumn?: string;
 }
 
 const operatorOptions: Array<ComboboxOption<string>> = [
@@ -25,7 +29,7 @@ const operatorOptions: Array<ComboboxOption<string>> = [
 
 const noValueOperators = ['IS NULL', 'IS NOT NULL'];
 
-export function WhereEditor({ datasource, table, value, onChange }: Props) {
+export function WhereEditor({ datasource, table, value, onChange, range, timeColumn }: Props) {
   const [availableColumns, setAvailableColumns] = useState<ColumnInfo[]>([]);
 
   useEffect(() => {
@@ -55,6 +59,22 @@ export function WhereEditor({ datasource, table, value, onChange }: Props) {
     value: c.name,
   }));
 
+  // The time column is only passed for tables that have it, as the lookup
+  // fails on unknown columns.
+  const rangeColumn = availableColumns.some((c) => c.name === timeColumn) ? timeColumn : undefined;
+
+  const loadValueOptions = (column: string) => async (prefix: string) => {
+    if (!table || !column) {
+      return [];
+    }
+    try {
+      const values = await datasource.getColumnValues(table, column, prefix, range, rangeColumn);
+      return values.map((v) => ({ label: v, value: v }));
+    } catch {
+      return [];
+    }
+  };
+
   const addCondition = () => {
     onChange([...value, { column: '', operator: '=', value: '' }]);
   };
@@ -94,9 +114,12 @@ export function WhereEditor({ datasource, table, value, onChange }: Props) {
             width={15}
           />
           {!noValueOperators.includes(cond.operator) && (
-            <Input
-              value={cond.value}
-              onChange={(e) => updateCondition(idx, 'value', e.currentTarget.value)}
+            <Combobox
+              options={loadValueOptions(cond.column)}
+              value={cond.value || null}
+              onChange={(option) => updateCondition(idx, 'value', option?.value ?? '')}
+              createCustomValue
+              isClearable
               placeholder="Value"
               width={20}
             />
@@ -28,7 +28,7 @@ FROM "users"
 WHERE "name" = 'O''Brien'`);
   });
 
-  it('quotes safe identifiers and builds supported clauses', () => {
+  it('quotes identifiers and builds supported clauses', () => {
     const sql = generateSQL(
       buildState({
         table: 'sales',
@@ -65,14 +65,29 @@ FROM "users"
 WHERE "name" IN ('O''Brien', 'Smith')`);
   });
 
-  it.each([
-    ['table', { table: 'users;DROP' }],
-    ['selected column', { columns: [{ name: 'name;DROP', aggregation: '' }] }],
-    ['where column', { whereClause: [{ column: 'name;DROP', operator: '=', value: 'Ada' }] }],
-    ['group by column', { groupBy: ['name;DROP'] }],
-    ['order by column', { orderBy: [{ column: 'name;DROP', direction: 'ASC' }] }],
-  ])('returns an empty query for an unsafe %s identifier', (_case, overrides) => {
-    expect(generateSQL(buildState(overrides as Partial<BuilderState>))).toBe('');
+  it('quotes identifiers with hyphens, spaces, quotes and non-ASCII characters', () => {
+    const sql = generateSQL(
+      buildState({
+        table: 'sales-2024 "eu"',
+        columns: [
+          { name: 'région', aggregation: '' },
+          { name: 'net amount', aggregation: 'SUM' },
+        ],
+        whereClause: [{ column: 'name;DROP', operator: '=', value: 'Ada' }],
+        groupBy: ['région'],
+        orderBy: [{ column: 'net amount', direction: 'DESC' }],
+      })
+    );
+
+    expect(sql).toBe(`SELECT "région", SUM("net amount")
+FROM "sales-2024 ""eu"""
+WHERE "name;DROP" = 'Ada'
+GROUP BY "région"
+ORDER BY "net amount" DESC`);
+  });
+
+  it('returns an empty query for an empty column name', () => {
+    expect(generateSQL(buildState({ columns: [{ name: '', aggregation: '' }] }))).toBe('');
   });
 
   it.each([
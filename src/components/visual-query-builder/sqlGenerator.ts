@@ -10,7 +10,6 @@ export interface BuilderState {
   offset: string;
 }
 
-const safeIdentifierPattern = /^[A-Za-z0-9_]+$/;
 const aggregateFunctions = new Set(['COUNT', 'SUM', 'AVG', 'MIN', 'MAX']);
 const operators = new Set(['=', '!=', '<', '>', '<=', '>=', 'LIKE', 'IN', 'IS NULL', 'IS NOT NULL']);
 const noValueOperators = new Set(['IS NULL', 'IS NOT NULL']);
@@ -21,7 +20,7 @@ export function generateSQL(state: BuilderState): string {
   if (!state.table) {
     return '';
   }
-  const table = quoteSafeIdentifier(state.table);
+  const table = quoteIdentifier(state.table);
   if (!table) {
     return '';
   }
@@ -94,7 +93,7 @@ function buildSelectColumns(columns: ColumnSelection[]): string | null {
   const parts: string[] = [];
 
   for (const col of columns) {
-    const column = quoteSafeIdentifier(col.name);
+    const column = quoteIdentifier(col.name);
     if (!column) {
       return null;
     }
@@ -122,7 +121,7 @@ function buildWhere(conditions: WhereCondition[]): string | null {
       continue;
     }
 
-    const column = quoteSafeIdentifier(condition.column);
+    const column = quoteIdentifier(condition.column);
     const operator = normalizeOperator(condition.operator);
     if (!column || !operator) {
       return null;
@@ -152,7 +151,7 @@ function buildOrderBy(orderBy: OrderByClause[]): string | null {
       continue;
     }
 
-    const column = quoteSafeIdentifier(order.column);
+    const column = quoteIdentifier(order.column);
     const direction = (order.direction || 'ASC').trim().toUpperCase();
     if (!column || !orderDirections.has(direction)) {
       return null;
@@ -168,7 +167,7 @@ function buildIdentifierList(identifiers: string[]): string | null {
   const parts: string[] = [];
 
   for (const identifier of identifiers) {
-    const quoted = quoteSafeIdentifier(identifier);
+    const quoted = quoteIdentifier(identifier);
     if (!quoted) {
       return null;
     }
@@ -192,11 +191,14 @@ function buildInValues(value: string): string {
   return value.split(',').map((item) => quoteStringLiteral(item.trim())).join(', ');
 }
 
-function quoteSafeIdentifier(identifier: string): string | null {
-  if (!safeIdentifierPattern.test(identifier)) {
+// quoteIdentifier quotes a SQLite identifier by wrapping it in double quotes
+// and doubling embedded double quotes, like quoteIdentifier in the backend.
+// Any name is allowed, since tables and columns are picked from the schema.
+function quoteIdentifier(identifier: string): string | null {
+  if (!identifier) {
     return null;
   }
-  return `"${identifier}"`;
+  return `"${identifier.replace(/"/g, '""')}"`;
 }
 
 function quoteStringLiteral(value: string): string {
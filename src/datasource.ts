@@ -1,7 +1,23 @@
-import { DataSourceInstanceSettings, CoreApp, ScopedVars } from '@grafana/data';
+import {
+  AdHocVariableFilter,
+  CoreApp,
+  DataSourceGetTagKeysOptions,
+  DataSourceGetTagValuesOptions,
+  DataSourceInstanceSettings,
+  MetricFindValue,
+  ScopedVars,
+  TimeRange,
+} from '@grafana/data';
 import { DataSourceWithBackend, getTemplateSrv } from '@grafana/runtime';
 
-import { RqliteQuery, RqliteDataSourceOptions, DEFAULT_QUERY, ColumnInfo } from './types';
+import {
+  RqliteQuery,
+  RqliteDataSourceOptions,
+  DEFAULT_QUERY,
+  ColumnInfo,
+  ExplainResponse,
+  ValidateResponse,
+} from './types';
 import { RqliteVariableSupport } from './variables';
 
 export class DataSource extends DataSourceWithBackend<RqliteQuery, RqliteDataSourceOptions> {
@@ -14,10 +30,11 @@ export class DataSource extends DataSourceWithBackend<RqliteQuery, RqliteDataSou
     return DEFAULT_QUERY;
   }
 
-  applyTemplateVariables(query: RqliteQuery, scopedVars: ScopedVars) {
+  applyTemplateVariables(query: RqliteQuery, scopedVars: ScopedVars, filters?: AdHocVariableFilter[]) {
     return {
       ...query,
       rawSql: getTemplateSrv().replace(query.rawSql, scopedVars),
+      adhocFilters: filters ?? [],
     };
   }
 
@@ -32,4 +49,46 @@ export class DataSource extends DataSourceWithBackend<RqliteQuery, RqliteDataSou
   async getColumns(table: string): Promise<ColumnInfo[]> {
     return this.getResource('/columns', { table });
   }
+
+  async getTagKeys(_options?: DataSourceGetTagKeysOptions<RqliteQuery>): Promise<MetricFindValue[]> {
+    const keys: string[] = await this.getResource('/tag-keys');
+    return keys.map((text) => ({ text }));
+  }
+
+  async getTagValues(options: DataSourceGetTagValuesOptions<RqliteQuery>): Promise<MetricFindValue[]> {
+    const values: string[] = await this.getResource('/tag-values', { key: options.key });
+    return values.map((text) => ({ text }));
+  }
+
+  async getColumnValues(
+    table: string,
+    column: string,
+    prefix = '',
+    range?: TimeRange,
+    timeColumn?: string
+  ): Promise<string[]> {
+    const params: Record<string, string> = { table, column, prefix };
+    if (range && timeColumn) {
+      params.timeColumn = timeColumn;
+      params.from = String(range.from.valueOf());
+      params.to = String(range.to.valueOf());
+    }
+    return this.getResource('/values', params);
+  }
+
+  async explainQuery(query: RqliteQuery, range: TimeRange, scopedVars: ScopedVars = {}): Promise<ExplainResponse> {
+    return this.postResource('/explain', {
+      query: this.applyTemplateVariables(query, scopedVars),
+      from: range.from.valueOf(),
+      to: range.to.valueOf(),
+    });
+  }
+
+  async validateQuery(query: RqliteQuery, range: TimeRange, scopedVars: ScopedVars = {}): Promise<ValidateResponse> {
+    return this.postResource('/validate', {
+      query: this.applyTemplateVariables(query, scopedVars),
+      from: range.from.valueOf(),
+      to: range.to.valueOf(),
+    });
+  }
 }
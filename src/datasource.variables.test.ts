@@ -1,7 +1,7 @@
 import { VariableSupportType } from '@grafana/data';
 
 import { DataSource } from './datasource';
-import { DEFAULT_QUERY } from './types';
+import { DEFAULT_QUERY, VARIABLE_QUERY_TYPE } from './types';
 
 describe('DataSource variable support', () => {
   const createDataSource = () => {
@@ -24,13 +24,16 @@ describe('DataSource variable support', () => {
     expect(ds.variables?.getType()).toBe(VariableSupportType.Datasource);
   });
 
-  it('exposes table defaults for variable queries', () => {
+  it('exposes variable query defaults', () => {
     const ds = createDataSource();
     const query = ds.variables?.getDefaultQuery?.();
 
     expect(query).toEqual({
       ...DEFAULT_QUERY,
       format: 'table',
+      queryType: VARIABLE_QUERY_TYPE,
+      variableRegex: '',
+      variableSort: '',
     });
   });
 });
@@ -1,8 +1,33 @@
-import { DataSourceJsonData } from '@grafana/data';
+import { AdHocVariableFilter, DataSourceJsonData } from '@grafana/data';
 import { DataQuery } from '@grafana/schema';
 
 export type EditorMode = 'code' | 'builder';
 export type QueryFormat = 'table' | 'time_series';
+export type DownsampleMode = '' | 'lttb' | 'minmax';
+export type BlobMode = 'base64' | 'hex' | 'utf8' | 'length' | 'datauri';
+
+// A time column with settings for parsing its values, see the README
+export interface TimeColumn {
+  name: string;
+  // Go layout or strftime format of text values
+  format?: string;
+  // Unit of Unix times, told by magnitude by default
+  unit?: 's' | 'ms' | 'us' | 'ns';
+  // IANA time zone of text values without an offset, UTC by default
+  timezone?: string;
+}
+
+export const timeColumnName = (col: string | TimeColumn) => (typeof col === 'string' ? col : col.name);
+export type VariableSort =
+  | ''
+  | 'alphabetical-asc'
+  | 'alphabetical-desc'
+  | 'alphabetical-ci-asc'
+  | 'alphabetical-ci-desc'
+  | 'numerical-asc'
+  | 'numerical-desc';
+
+export const VARIABLE_QUERY_TYPE = 'variable';
 
 export interface ColumnSelection {
   name: string;
@@ -23,7 +48,17 @@ export interface OrderByClause {
 export interface RqliteQuery extends DataQuery {
   rawSql: string;
   format: QueryFormat;
-  timeColumns: string[];
+  timeColumns: Array<string | TimeColumn>;
+  // Detect time columns by type, name and values when timeColumns is empty
+  detectTimeColumns?: boolean;
+  downsample?: DownsampleMode;
+  // Rendering of BLOB columns, blobColumns overrides blobMode by column name
+  blobMode?: BlobMode;
+  blobColumns?: Record<string, BlobMode>;
+  // Turn integer columns holding only 0 and 1 into booleans
+  inferBooleans?: boolean;
+  // Split queries with a time filter into chunks of this length, such as '1d'
+  splitDuration?: string;
   editorMode: EditorMode;
 
   // Visual builder fields
@@ -34,6 +69,18 @@ export interface RqliteQuery extends DataQuery {
   orderBy: OrderByClause[];
   limit: string;
   offset: string;
+
+  // Variable query fields, applied by the backend for queryType 'variable'
+  variableRegex?: string;
+  variableSort?: VariableSort;
+
+  // Live mode streams rows past the high-water mark on liveColumn, which
+  // defaults to the first time column
+  live?: boolean;
+  liveColumn?: string;
+
+  // Set by applyTemplateVariables from the dashboard's ad hoc filters
+  adhocFilters?: AdHocVariableFilter[];
 }
 
 export const DEFAULT_QUERY: Partial<RqliteQuery> = {
@@ -53,9 +100,75 @@ export const DEFAULT_QUERY: Partial<RqliteQuery> = {
 export interface RqliteDataSourceOptions extends DataSourceJsonData {
   consistencyLevel?: string;
   timeout?: string;
+  largeTableRows?: number;
+  maxRows?: number;
+  maxResponseBytes?: number;
+  limitAction?: 'truncate' | 'reject';
+  maxRetries?: number;
+  breakerErrorRate?: number;
+  breakerLatency?: string;
+  breakerCooldown?: string;
+  maxConcurrentQueries?: number;
+  liveInterval?: string;
+  splitConcurrency?: number;
+  chunkCacheSize?: number;
+  auditLog?: boolean;
+  auditSql?: 'normalized' | 'raw' | 'none';
+  identityForwarding?: boolean;
+  tenantPolicy?: TenantPolicy;
+  allowedTables?: string[];
+  deniedTables?: string[];
+}
+
+export interface TenantPolicy {
+  /** Tenant by Grafana login. */
+  users?: Record<string, string>;
+  /** Tenant by org ID. */
+  orgs?: Record<string, string>;
+  /** Tenant column by protected table. */
+  tables?: Record<string, string>;
+}
+
+export interface RqliteSecureJsonData {
+  /** JSON mapping Grafana logins and roles to rqlite credentials, see the README. */
+  identityMap?: string;
 }
 
 export interface ColumnInfo {
   name: string;
   type: string;
 }
+
+export interface PlanNode {
+  id: number;
+  parent: number;
+  detail: string;
+  children?: PlanNode[];
+}
+
+export interface PlanWarning {
+  severity: 'info' | 'warning';
+  message: string;
+  table?: string;
+}
+
+export interface ExplainResponse {
+  sql: string;
+  plan: PlanNode[];
+  warnings: PlanWarning[];
+}
+
+export interface SQLError {
+  source: 'macro' | 'sql';
+  message: string;
+  line: number;
+  column: number;
+  endLine: number;
+  endColumn: number;
+}
+
+export interface ValidateResponse {
+  valid: boolean;
+  sql: string;
+  errors: SQLError[];
+}
@@ -1,6 +1,6 @@
 import { DataSourceVariableSupport } from '@grafana/data';
 
-import { DEFAULT_QUERY, RqliteQuery } from './types';
+import { DEFAULT_QUERY, RqliteQuery, VARIABLE_QUERY_TYPE } from './types';
 import type { DataSource } from './datasource';
 
 export class RqliteVariableSupport extends DataSourceVariableSupport<DataSource, RqliteQuery> {
@@ -8,6 +8,9 @@ export class RqliteVariableSupport extends DataSourceVariableSupport<DataSource,
     return {
       ...DEFAULT_QUERY,
       format: 'table',
+      queryType: VARIABLE_QUERY_TYPE,
+      variableRegex: '',
+      variableSort: '',
     };
   }
 }